@@ -0,0 +1,367 @@
+package cache
+
+import (
+	"bytes"
+	"container/list"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/lance4117/gofuse/codec"
+	"github.com/lance4117/gofuse/errs"
+)
+
+// Policy Bounded 的淘汰策略
+type Policy int
+
+const (
+	PolicyLRU     Policy = iota // 最近最少使用
+	PolicyTinyLFU               // W-TinyLFU：LRU 窗口 + SLRU 主区，按访问频率决定是否准入主区
+)
+
+// BoundedConfig 有界缓存配置，MaxEntries 与 MaxBytes 同时设置时两者都生效
+type BoundedConfig struct {
+	MaxEntries int           // 最大条目数，0 表示不限；两者都未设置时默认 10000
+	MaxBytes   int64         // 最大占用字节数（key + value），0 表示不限
+	Policy     Policy        // 淘汰策略，默认 PolicyLRU
+	ExpTime    time.Duration // 全局过期时间，0为不过期
+}
+
+// limit 条目数与字节数的上限，0 表示不限
+type limit struct {
+	entries int
+	bytes   int64
+}
+
+// scale 按比例缩放上限，已设置的上限至少为 1
+func (l limit) scale(f float64) limit {
+	out := limit{entries: int(float64(l.entries) * f), bytes: int64(float64(l.bytes) * f)}
+	if l.entries > 0 {
+		out.entries = max(out.entries, 1)
+	}
+	if l.bytes > 0 {
+		out.bytes = max(out.bytes, 1)
+	}
+	return out
+}
+
+// exceeded 判断给定用量是否超出上限
+func (l limit) exceeded(entries int, bytes int64) bool {
+	return (l.entries > 0 && entries > l.entries) || (l.bytes > 0 && bytes > l.bytes)
+}
+
+// bnode 有界缓存中的条目
+type bnode struct {
+	key      string
+	value    []byte
+	expireAt int64
+	seg      *segment
+	elem     *list.Element
+}
+
+func (n *bnode) cost() int64 {
+	return int64(len(n.key) + len(n.value))
+}
+
+// segment 一段 LRU 链表，表头为最近访问
+type segment struct {
+	ll      *list.List
+	entries int
+	bytes   int64
+	max     limit
+}
+
+func newSegment(max limit) *segment {
+	return &segment{ll: list.New(), max: max}
+}
+
+func (s *segment) over() bool {
+	return s.max.exceeded(s.entries, s.bytes)
+}
+
+func (s *segment) pushFront(n *bnode) {
+	n.seg, n.elem = s, s.ll.PushFront(n)
+	s.entries++
+	s.bytes += n.cost()
+}
+
+func (s *segment) remove(n *bnode) {
+	s.ll.Remove(n.elem)
+	s.entries--
+	s.bytes -= n.cost()
+	n.seg, n.elem = nil, nil
+}
+
+func (s *segment) back() *bnode {
+	if e := s.ll.Back(); e != nil {
+		return e.Value.(*bnode)
+	}
+	return nil
+}
+
+// Bounded 按条目数或内存上限淘汰的进程内缓存，支持 LRU 与 W-TinyLFU，
+// 过期条目在访问时惰性删除，或随容量淘汰被移除
+type Bounded struct {
+	mu    sync.Mutex
+	cfg   BoundedConfig
+	items map[string]*bnode
+
+	window    *segment // LRU 模式下为唯一的分段
+	probation *segment
+	protected *segment
+	main      limit // probation + protected 的总上限
+	sketch    *cmSketch
+
+	hits, misses, evictions int64
+}
+
+// NewBounded 初始化有界缓存
+func NewBounded(cfg BoundedConfig) (*Bounded, error) {
+	if cfg.MaxEntries <= 0 && cfg.MaxBytes <= 0 {
+		cfg.MaxEntries = 10000
+	}
+	b := &Bounded{cfg: cfg}
+	b.init()
+	return b, nil
+}
+
+func (b *Bounded) init() {
+	total := limit{entries: b.cfg.MaxEntries, bytes: b.cfg.MaxBytes}
+	b.items = make(map[string]*bnode)
+	if b.cfg.Policy != PolicyTinyLFU {
+		b.window = newSegment(total)
+		return
+	}
+
+	// 窗口占 1%，主区中受保护段占 80%
+	window := total.scale(0.01)
+	b.main = total
+	if total.entries > 0 {
+		b.main.entries = max(total.entries-window.entries, 1)
+	}
+	if total.bytes > 0 {
+		b.main.bytes = max(total.bytes-window.bytes, 1)
+	}
+	b.window = newSegment(window)
+	b.probation = newSegment(limit{})
+	b.protected = newSegment(b.main.scale(0.8))
+
+	capacity := b.cfg.MaxEntries
+	if capacity <= 0 {
+		capacity = int(b.cfg.MaxBytes / 64)
+	}
+	b.sketch = newCMSketch(max(capacity, 64))
+}
+
+// Set 将指定key存储序列化数据
+func (b *Bounded) Set(key string, value any) error {
+	return b.SetWithTTL(key, value, 0)
+}
+
+// SetWithTTL 将指定key存储序列化数据，并单独指定过期时间
+func (b *Bounded) SetWithTTL(key string, value any, ttl time.Duration) error {
+	marshal, err := codec.MPMarshal(value)
+	if err != nil {
+		return err
+	}
+	return b.SetBytesWithTTL(key, marshal, ttl)
+}
+
+// SetBytes 将指定key存储原始字节
+func (b *Bounded) SetBytes(key string, value []byte) error {
+	return b.SetBytesWithTTL(key, value, 0)
+}
+
+// SetBytesWithTTL 将指定key存储原始字节，并单独指定过期时间，ttl<=0 时使用全局过期时间
+func (b *Bounded) SetBytesWithTTL(key string, value []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = b.cfg.ExpTime
+	}
+	var expireAt int64
+	if ttl > 0 {
+		expireAt = time.Now().Add(ttl).UnixNano()
+	}
+	if b.cfg.MaxBytes > 0 && int64(len(key)+len(value)) > b.cfg.MaxBytes {
+		return errs.ErrEntryTooLarge
+	}
+	value = bytes.Clone(value)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.sketch != nil {
+		b.sketch.increment(key)
+	}
+	if n, ok := b.items[key]; ok {
+		seg := n.seg
+		seg.remove(n)
+		n.value, n.expireAt = value, expireAt
+		seg.pushFront(n)
+	} else {
+		n = &bnode{key: key, value: value, expireAt: expireAt}
+		b.items[key] = n
+		b.window.pushFront(n)
+	}
+	b.evict()
+	return nil
+}
+
+// Get 根据key 将 v指针,返回对应数据
+func (b *Bounded) Get(key string, v any) error {
+	if v == nil || reflect.TypeOf(v).Kind() != reflect.Ptr {
+		return errs.ErrNeedPointer
+	}
+	bytes, err := b.GetBytes(key)
+	if err != nil {
+		return err
+	}
+	return codec.MPUnmarshal(bytes, v)
+}
+
+// GetBytes 根据key 返回原始字节，不存在或已过期时返回 ErrEntryNotFound
+func (b *Bounded) GetBytes(key string) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.sketch != nil {
+		b.sketch.increment(key)
+	}
+	n, ok := b.items[key]
+	if !ok {
+		b.misses++
+		return nil, ErrEntryNotFound
+	}
+	if expired(n.expireAt, time.Now().UnixNano()) {
+		b.misses++
+		b.drop(n)
+		return nil, ErrEntryNotFound
+	}
+	b.hits++
+
+	switch n.seg {
+	case b.probation:
+		// 再次访问，晋升到受保护段
+		b.probation.remove(n)
+		b.protected.pushFront(n)
+		b.evict()
+	default:
+		n.seg.ll.MoveToFront(n.elem)
+	}
+	return bytes.Clone(n.value), nil
+}
+
+// Delete 删除
+func (b *Bounded) Delete(key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n, ok := b.items[key]
+	if !ok {
+		return ErrEntryNotFound
+	}
+	b.drop(n)
+	return nil
+}
+
+// Len 当前缓存长度，包含已过期但尚未被清理的 key
+func (b *Bounded) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.items)
+}
+
+// Stats 返回缓存统计信息
+func (b *Bounded) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	size := b.window.bytes
+	if b.sketch != nil {
+		size += b.probation.bytes + b.protected.bytes
+	}
+	return Stats{
+		Hits:      b.hits,
+		Misses:    b.misses,
+		Evictions: b.evictions,
+		Entries:   len(b.items),
+		Bytes:     int(size),
+	}
+}
+
+// Reset 重置缓存
+func (b *Bounded) Reset() error {
+	b.mu.Lock()
+	b.init()
+	b.mu.Unlock()
+	return nil
+}
+
+// Close 关闭缓存
+func (b *Bounded) Close() error {
+	return b.Reset()
+}
+
+// evict 将各分段调整回上限以内
+func (b *Bounded) evict() {
+	for b.window.over() {
+		n := b.window.back()
+		if b.sketch == nil {
+			b.drop(n)
+			continue
+		}
+		b.window.remove(n)
+		b.admit(n)
+	}
+	if b.sketch == nil {
+		return
+	}
+	// 受保护段超限时降级到试用段
+	for b.protected.over() {
+		n := b.protected.back()
+		b.protected.remove(n)
+		b.probation.pushFront(n)
+	}
+	for b.main.exceeded(b.probation.entries+b.protected.entries, b.probation.bytes+b.protected.bytes) {
+		b.drop(b.mainVictim())
+	}
+}
+
+// admit 窗口淘汰的候选者与主区的淘汰者比较访问频率，频率更高者留下；
+// 先确定腾出空间所需的全部淘汰者，候选者无法放入或频率不占优时直接丢弃候选者，不淘汰主区条目
+func (b *Bounded) admit(cand *bnode) {
+	if b.main.exceeded(1, cand.cost()) {
+		b.drop(cand)
+		return
+	}
+	freq := b.sketch.estimate(cand.key)
+	entries, size := b.probation.entries+b.protected.entries+1, b.probation.bytes+b.protected.bytes+cand.cost()
+	var victims []*bnode
+	for _, seg := range []*segment{b.probation, b.protected} {
+		for e := seg.ll.Back(); e != nil && b.main.exceeded(entries, size); e = e.Prev() {
+			victim := e.Value.(*bnode)
+			if freq <= b.sketch.estimate(victim.key) {
+				b.drop(cand)
+				return
+			}
+			victims = append(victims, victim)
+			entries, size = entries-1, size-victim.cost()
+		}
+	}
+	for _, victim := range victims {
+		b.drop(victim)
+	}
+	b.probation.pushFront(cand)
+}
+
+// mainVictim 主区中下一个被淘汰的条目
+func (b *Bounded) mainVictim() *bnode {
+	if n := b.probation.back(); n != nil {
+		return n
+	}
+	return b.protected.back()
+}
+
+// drop 移除条目
+func (b *Bounded) drop(n *bnode) {
+	if n.seg != nil {
+		n.seg.remove(n)
+	}
+	delete(b.items, n.key)
+	b.evictions++
+}
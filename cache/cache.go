@@ -1,31 +1,142 @@
+// Package cache 基于 BigCache 的内存缓存。
+//
+// Cache 支持全局及单 key 过期时间、泛型 API（Typed）、防击穿加载（GetOrLoad）、
+// 标签/前缀批量失效、快照预热与统计指标；Bounded 为按条目数或字节数限制的
+// LRU/W-TinyLFU 有界缓存；Tiered 为基于 kvs 的二级缓存，支持写穿与异步写回。
 package cache
 
 import (
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/allegro/bigcache"
 	"github.com/lance4117/gofuse/codec"
 	"github.com/lance4117/gofuse/errs"
+	"golang.org/x/sync/singleflight"
 )
 
+// noExpiry 全局过期时间为0时使用的生命窗口，BigCache 本身不支持永不过期
+const noExpiry = 100 * 365 * 24 * time.Hour
+
+// Config 缓存配置
+type Config struct {
+	ExpTime       time.Duration // 全局过期时间，0为不过期；单个 key 的 TTL 不会超过该窗口
+	CleanInterval time.Duration // 后台清理过期 key 的间隔，默认 1s
+	Load          LoadOptions   // GetOrLoad 默认的加载策略
+}
+
+// DefaultConfig 返回默认配置
+func DefaultConfig(expTime time.Duration) Config {
+	return Config{
+		ExpTime:       expTime,
+		CleanInterval: time.Second,
+	}
+}
+
 type Cache struct {
+	// BigCache 底层存储，值中带有条目头与 key，直接调用其 Get/Set 会读到无法解析的数据或写入缓存无法识别的条目。
+	//
+	// Deprecated: 请使用 Cache 的 Get/Set/GetBytes/SetBytes/Delete/Len/Reset/Stats 等方法，不要直接访问。
 	BigCache *bigcache.BigCache
+
+	expiry    expiryIndex
+	locks     keyLocks
+	tags      tagIndex
+	group     singleflight.Group
+	loadOpts  LoadOptions
+	life      time.Duration // 全局过期时间，noExpiry 表示不过期
+	stop      chan struct{}
+	closeOnce sync.Once
+
+	hits      atomic.Int64
+	misses    atomic.Int64
+	evictions atomic.Int64
+	evictCh   chan evictEvent
+	evictMu   sync.RWMutex
+	onEvict   []func(key string, reason EvictReason)
 }
 
 // NewCache 初始化带过期时间的缓存,0为不过期
 func NewCache(expTime time.Duration) (*Cache, error) {
-	cache, err := bigcache.NewBigCache(bigcache.DefaultConfig(expTime))
-	return &Cache{cache}, err
+	return NewCacheWithConfig(DefaultConfig(expTime))
+}
+
+// NewCacheWithConfig 根据配置初始化缓存
+func NewCacheWithConfig(cfg Config) (*Cache, error) {
+	if cfg.ExpTime <= 0 {
+		cfg.ExpTime = noExpiry
+	}
+	if cfg.CleanInterval <= 0 {
+		cfg.CleanInterval = time.Second
+	}
+	c := &Cache{
+		loadOpts: cfg.Load,
+		life:     cfg.ExpTime,
+		stop:     make(chan struct{}),
+		evictCh:  make(chan evictEvent, 4096),
+	}
+	bcCfg := bigcache.DefaultConfig(cfg.ExpTime)
+	bcCfg.OnRemoveWithReason = c.onRemove
+	bc, err := bigcache.NewBigCache(bcCfg)
+	if err != nil {
+		return nil, err
+	}
+	c.BigCache = bc
+	go c.janitor(cfg.CleanInterval)
+	go c.dispatchEvictions()
+	return c, nil
 }
 
 // Set 将指定key存储序列化数据
 func (i *Cache) Set(key string, value any) error {
+	return i.SetWithTTL(key, value, 0)
+}
+
+// SetWithTTL 将指定key存储序列化数据，并单独指定过期时间，ttl<=0 时仅受全局过期时间约束
+func (i *Cache) SetWithTTL(key string, value any, ttl time.Duration) error {
+	return i.set(key, value, ttl, nil)
+}
+
+func (i *Cache) set(key string, value any, ttl time.Duration, tags []string) error {
 	marshal, err := codec.MPMarshal(value)
 	if err != nil {
 		return err
 	}
-	return i.BigCache.Set(key, marshal)
+	return i.setEntry(key, newHeader(ttl), marshal, tags)
+}
+
+// SetBytes 将指定key存储原始字节，不做序列化
+func (i *Cache) SetBytes(key string, value []byte) error {
+	return i.SetBytesWithTTL(key, value, 0)
+}
+
+// SetBytesWithTTL 将指定key存储原始字节，并单独指定过期时间
+func (i *Cache) SetBytesWithTTL(key string, value []byte, ttl time.Duration) error {
+	return i.setEntry(key, newHeader(ttl), value, nil)
+}
+
+// setEntry 写入带头部的条目，覆盖标签并登记过期索引
+func (i *Cache) setEntry(key string, h entryHeader, value []byte, tags []string) error {
+	if len(key) > maxKeyLen {
+		return errs.ErrKeyTooLong
+	}
+	l := i.locks.lock(key)
+	err := i.BigCache.Set(key, wrapValue(key, h, value))
+	if err == nil {
+		i.tags.set(key, tags)
+	}
+	l.Unlock()
+	if err != nil {
+		return err
+	}
+	if deadline := h.deadline(); deadline > 0 {
+		i.expiry.add(key, deadline)
+	} else {
+		i.expiry.remove(key)
+	}
+	return nil
 }
 
 // Get 根据key 将 v指针,返回对应数据
@@ -37,29 +148,118 @@ func (i *Cache) Get(key string, v any) error {
 	if reflect.TypeOf(v).Kind() != reflect.Ptr {
 		return errs.ErrNeedPointer
 	}
-	bytes, err := i.BigCache.Get(key)
+	bytes, err := i.GetBytes(key)
 	if err != nil {
 		return err
 	}
 	return codec.MPUnmarshal(bytes, v)
 }
 
+// GetBytes 根据key 返回原始字节，不存在或已过期时返回 bigcache.ErrEntryNotFound
+func (i *Cache) GetBytes(key string) ([]byte, error) {
+	entry, err := i.BigCache.Get(key)
+	if err != nil {
+		i.misses.Add(1)
+		return nil, err
+	}
+	h, value := unwrapValue(entry)
+	if h.flags&flagNegative != 0 {
+		i.misses.Add(1)
+		return nil, bigcache.ErrEntryNotFound
+	}
+	if expired(h.expireAt, time.Now().UnixNano()) {
+		i.misses.Add(1)
+		// 惰性删除
+		i.expire(key)
+		return nil, bigcache.ErrEntryNotFound
+	}
+	i.hits.Add(1)
+	return value, nil
+}
+
+// TTL 返回key 剩余的过期时间，未单独设置过期时间时返回 NoTTL
+func (i *Cache) TTL(key string) (time.Duration, error) {
+	entry, err := i.BigCache.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	h, _ := unwrapValue(entry)
+	if h.flags&flagNegative != 0 {
+		return 0, bigcache.ErrEntryNotFound
+	}
+	if h.expireAt == 0 {
+		return NoTTL, nil
+	}
+	remain := time.Duration(h.expireAt - time.Now().UnixNano())
+	if remain <= 0 {
+		i.expire(key)
+		return 0, bigcache.ErrEntryNotFound
+	}
+	return remain, nil
+}
+
 // Delete 删除
 func (i *Cache) Delete(key string) error {
-	return i.BigCache.Delete(key)
+	l := i.locks.lock(key)
+	defer l.Unlock()
+	return i.delete(key)
 }
 
-// Len 当前缓存长度
+// delete 删除 key 并同步清理标签，调用方需持有 key 锁
+func (i *Cache) delete(key string) error {
+	err := i.BigCache.Delete(key)
+	if err == nil {
+		i.tags.remove(key)
+		i.expiry.remove(key)
+	}
+	return err
+}
+
+// Len 当前缓存长度，包含已过期但尚未被清理的 key
 func (i *Cache) Len() int {
 	return i.BigCache.Len()
 }
 
 // Reset 重置缓存
 func (i *Cache) Reset() error {
+	i.expiry.reset()
+	i.tags.reset()
 	return i.BigCache.Reset()
 }
 
 // Close 关闭缓存
 func (i *Cache) Close() error {
+	i.closeOnce.Do(func() {
+		close(i.stop)
+	})
 	return i.BigCache.Close()
 }
+
+// expire 在持有 key 锁的情况下确认 key 已到删除时间后删除，避免误删并发写入的新值
+func (i *Cache) expire(key string) {
+	l := i.locks.lock(key)
+	defer l.Unlock()
+	entry, err := i.BigCache.Get(key)
+	if err != nil {
+		return
+	}
+	if h, _ := unwrapValue(entry); expired(h.deadline(), time.Now().UnixNano()) {
+		_ = i.delete(key)
+	}
+}
+
+// janitor 后台定期清理已过期的 key
+func (i *Cache) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-i.stop:
+			return
+		case <-ticker.C:
+			for _, item := range i.expiry.popExpired(time.Now().UnixNano()) {
+				i.expire(item.key)
+			}
+		}
+	}
+}
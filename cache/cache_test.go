@@ -1,8 +1,23 @@
 package cache
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/allegro/bigcache"
+	"github.com/lance4117/gofuse/codec"
+	"github.com/lance4117/gofuse/errs"
+	"github.com/lance4117/gofuse/eventbus"
+	"github.com/lance4117/gofuse/store/kvs"
 )
 
 func TestCache(t *testing.T) {
@@ -31,3 +46,597 @@ func TestCache(t *testing.T) {
 	t.Log(value)
 	t.Log(cache.Len())
 }
+
+func TestTyped(t *testing.T) {
+	cache, err := NewTyped[codecUser](10*time.Second, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	_, ok, err := cache.Get("user:1")
+	if err != nil || ok {
+		t.Fatalf("expected miss, got ok=%v err=%v", ok, err)
+	}
+
+	if err = cache.Set("user:1", codecUser{Uid: 1, Name: "Tom"}); err != nil {
+		t.Fatal(err)
+	}
+	user, ok, err := cache.Get("user:1")
+	if err != nil || !ok || user.Name != "Tom" {
+		t.Fatalf("unexpected get result: %+v ok=%v err=%v", user, ok, err)
+	}
+
+	var loads int
+	loader := func() (codecUser, error) {
+		loads++
+		return codecUser{Uid: 2, Name: "Jerry"}, nil
+	}
+	for range 3 {
+		user, err = cache.GetOrLoad("user:2", loader)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if loads != 1 || user.Name != "Jerry" {
+		t.Fatalf("expected 1 load, got %d, user %+v", loads, user)
+	}
+}
+
+func TestTypedRaw(t *testing.T) {
+	cache, err := NewTyped(10*time.Second, codec.Raw())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	if err = cache.Set("raw", []byte("bytes")); err != nil {
+		t.Fatal(err)
+	}
+	data, ok, err := cache.Get("raw")
+	if err != nil || !ok || string(data) != "bytes" {
+		t.Fatalf("unexpected get result: %s ok=%v err=%v", data, ok, err)
+	}
+}
+
+type codecUser struct {
+	Uid  int64
+	Name string
+}
+
+func TestSetWithTTL(t *testing.T) {
+	cache, err := NewCacheWithConfig(Config{CleanInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	if err = cache.SetWithTTL("session", "token", 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err = cache.Set("forever", "value"); err != nil {
+		t.Fatal(err)
+	}
+
+	ttl, err := cache.TTL("session")
+	if err != nil || ttl <= 0 || ttl > 50*time.Millisecond {
+		t.Fatalf("unexpected ttl %v err=%v", ttl, err)
+	}
+	if ttl, _ = cache.TTL("forever"); ttl != NoTTL {
+		t.Fatalf("expected NoTTL, got %v", ttl)
+	}
+
+	var value string
+	if err = cache.Get("session", &value); err != nil || value != "token" {
+		t.Fatalf("unexpected get result %q err=%v", value, err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	// 后台清理后条目应被真正删除
+	if cache.Len() != 1 {
+		t.Fatalf("expected janitor to evict session, len=%d", cache.Len())
+	}
+	if err = cache.Get("session", &value); !errors.Is(err, bigcache.ErrEntryNotFound) {
+		t.Fatalf("expected ErrEntryNotFound, got %v", err)
+	}
+}
+
+func TestTTLOverwrite(t *testing.T) {
+	cache, err := NewCacheWithConfig(Config{CleanInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	if err = cache.SetWithTTL("key", "short", 30*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	// 覆盖为不过期，旧的索引项不应删除新值
+	if err = cache.Set("key", "long"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(80 * time.Millisecond)
+
+	var value string
+	if err = cache.Get("key", &value); err != nil || value != "long" {
+		t.Fatalf("unexpected get result %q err=%v", value, err)
+	}
+
+	// 反复覆盖同一 key，过期索引只保留一项
+	for i := 0; i < 1000; i++ {
+		_ = cache.SetWithTTL("session", i, time.Hour)
+	}
+	cache.expiry.mu.Lock()
+	n := cache.expiry.items.Len()
+	cache.expiry.mu.Unlock()
+	if n != 1 {
+		t.Fatalf("expected 1 expiry item, got %d", n)
+	}
+}
+
+func TestKeyTooLong(t *testing.T) {
+	cache, err := NewCache(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	if err = cache.SetBytes(strings.Repeat("k", 70000), []byte("v")); !errors.Is(err, errs.ErrKeyTooLong) {
+		t.Fatalf("expected key too long, got %v", err)
+	}
+	key := strings.Repeat("k", maxKeyLen)
+	if err = cache.SetBytes(key, []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := cache.GetBytes(key); err != nil || string(v) != "v" {
+		t.Fatalf("unexpected get result %q err=%v", v, err)
+	}
+}
+
+func TestGetOrLoadSingleflight(t *testing.T) {
+	cache, err := NewCache(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	var loads int32
+	loader := func(ctx context.Context) (any, error) {
+		atomic.AddInt32(&loads, 1)
+		time.Sleep(50 * time.Millisecond)
+		return "from-db", nil
+	}
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var value string
+			if err := cache.GetOrLoad(context.Background(), "hot", &value, loader); err != nil || value != "from-db" {
+				t.Errorf("unexpected result %q err=%v", value, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Fatalf("expected 1 load, got %d", n)
+	}
+}
+
+func TestGetOrLoadStale(t *testing.T) {
+	cache, err := NewCache(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	var version int32
+	loader := func(ctx context.Context) (any, error) {
+		return atomic.AddInt32(&version, 1), nil
+	}
+	opts := LoadOptions{TTL: 30 * time.Millisecond, StaleTTL: time.Second}
+
+	var value int32
+	if err = cache.GetOrLoadWithOptions(context.Background(), "k", &value, loader, opts); err != nil || value != 1 {
+		t.Fatalf("unexpected result %d err=%v", value, err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	// 过期后立即返回旧值，并在后台刷新
+	if err = cache.GetOrLoadWithOptions(context.Background(), "k", &value, loader, opts); err != nil || value != 1 {
+		t.Fatalf("expected stale value 1, got %d err=%v", value, err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err = cache.GetOrLoadWithOptions(context.Background(), "k", &value, loader, opts); err != nil || value != 2 {
+		t.Fatalf("expected refreshed value 2, got %d err=%v", value, err)
+	}
+}
+
+func TestGetOrLoadNegative(t *testing.T) {
+	cache, err := NewCacheWithConfig(Config{Load: LoadOptions{NegativeTTL: time.Second}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	var loads int32
+	loader := func(ctx context.Context) (any, error) {
+		atomic.AddInt32(&loads, 1)
+		return nil, errs.ErrKeyNotFound
+	}
+
+	var value string
+	for range 3 {
+		if err = cache.GetOrLoad(context.Background(), "missing", &value, loader); !errors.Is(err, errs.ErrKeyNotFound) {
+			t.Fatalf("expected ErrKeyNotFound, got %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Fatalf("expected 1 load, got %d", n)
+	}
+
+	// 负缓存对普通读取不可见，写入后立即生效
+	if err = cache.Get("missing", &value); !errors.Is(err, bigcache.ErrEntryNotFound) {
+		t.Fatalf("expected ErrEntryNotFound, got %v", err)
+	}
+	if err = cache.Set("missing", "now-exists"); err != nil {
+		t.Fatal(err)
+	}
+	if err = cache.GetOrLoad(context.Background(), "missing", &value, loader); err != nil || value != "now-exists" {
+		t.Fatalf("unexpected result %q err=%v", value, err)
+	}
+}
+
+func TestTiered(t *testing.T) {
+	l2, err := kvs.NewPebbleKV(kvs.NewPebbleConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l2.Close()
+
+	const topic eventbus.Topic = 1000
+	bus := eventbus.GetEventBus()
+	defer bus.Unsubscribe(topic)
+	notifier := NewEventBusNotifier(bus, topic)
+
+	newTiered := func(mode WriteMode) *Tiered {
+		l1, err := NewCache(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = l1.Close() })
+		tiered, err := NewTiered(l1, l2, TieredOptions{Mode: mode, Notifier: notifier})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = tiered.Close() })
+		return tiered
+	}
+	a, b := newTiered(WriteThrough), newTiered(WriteBehind)
+
+	// a 写入后 b 通过 L2 读穿透
+	if err = a.Set("user:1", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	var value string
+	if err = b.Get("user:1", &value); err != nil || value != "v1" {
+		t.Fatalf("unexpected result %q err=%v", value, err)
+	}
+
+	// a 更新后 b 的 L1 被失效，读到新值
+	if err = a.Set("user:1", "v2"); err != nil {
+		t.Fatal(err)
+	}
+	if err = b.Get("user:1", &value); err != nil || value != "v2" {
+		t.Fatalf("expected invalidated value v2, got %q err=%v", value, err)
+	}
+
+	// b 异步写入，Close 后保证已落到 L2
+	if err = b.Set("user:2", "behind"); err != nil {
+		t.Fatal(err)
+	}
+	_ = b.Close()
+	if err = a.Get("user:2", &value); err != nil || value != "behind" {
+		t.Fatalf("unexpected result %q err=%v", value, err)
+	}
+	if err = b.Set("user:3", "closed"); !errors.Is(err, errs.ErrCacheClosed) {
+		t.Fatalf("expected ErrCacheClosed after close, got %v", err)
+	}
+	if err = b.Delete("user:2"); !errors.Is(err, errs.ErrCacheClosed) {
+		t.Fatalf("expected ErrCacheClosed after close, got %v", err)
+	}
+
+	if err = a.Delete("user:1"); err != nil {
+		t.Fatal(err)
+	}
+	if err = a.Get("user:1", &value); !errors.Is(err, errs.ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestStatsAndOnEvict(t *testing.T) {
+	cache, err := NewCacheWithConfig(Config{CleanInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	evicted := make(chan EvictReason, 2)
+	cache.OnEvict(func(key string, reason EvictReason) {
+		evicted <- reason
+	})
+
+	_ = cache.Set("a", 1)
+	_ = cache.SetWithTTL("b", 2, 30*time.Millisecond)
+	var value int
+	_ = cache.Get("a", &value)
+	_ = cache.Get("missing", &value)
+	_ = cache.Delete("a")
+
+	for _, want := range []EvictReason{EvictDeleted, EvictExpired} {
+		select {
+		case got := <-evicted:
+			if got != want {
+				t.Fatalf("expected %s, got %s", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for %s", want)
+		}
+	}
+
+	s := cache.Stats()
+	if s.Hits != 1 || s.Misses != 1 || s.Evictions != 2 || s.HitRatio() != 0.5 || s.Capacity == 0 || s.Bytes != 0 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+
+	var buf bytes.Buffer
+	if err = cache.WritePrometheus(&buf, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `gofuse_cache_hits_total{cache="test"} 1`) {
+		t.Fatalf("unexpected prometheus output:\n%s", buf.String())
+	}
+
+	row, err := NewStatsCollector("Test", cache).Collect(nil, time.Now())
+	if err != nil || row[0] != "1" || row[2] != "50.00" {
+		t.Fatalf("unexpected collector row %v err=%v", row, err)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	src, err := NewCache(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	_ = src.Set("keep", "value")
+	_ = src.SetWithTTL("ttl", "value", time.Hour)
+	_ = src.SetWithTTL("short", "value", 30*time.Millisecond)
+
+	path := filepath.Join(t.TempDir(), "cache.snap")
+	if err = src.SaveSnapshotFile(path); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	dst, err := NewCache(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	if err = dst.LoadSnapshotFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// 已过期的条目在加载时被丢弃，剩余 TTL 保留
+	if dst.Len() != 2 {
+		t.Fatalf("expected 2 entries, got %d", dst.Len())
+	}
+	if ttl, err := dst.TTL("ttl"); err != nil || ttl <= 59*time.Minute {
+		t.Fatalf("unexpected ttl %v err=%v", ttl, err)
+	}
+	var value string
+	if err = dst.Get("keep", &value); err != nil || value != "value" {
+		t.Fatalf("unexpected result %q err=%v", value, err)
+	}
+
+	// 全局过期时间的剩余部分与标签随快照保存
+	global, err := NewCache(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer global.Close()
+	_ = global.SetWithTags("tagged", "value", "group")
+	var snap bytes.Buffer
+	if err = global.SaveSnapshot(&snap); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := NewCache(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+	if err = restored.LoadSnapshot(&snap); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, err := restored.TTL("tagged"); err != nil || ttl <= 58*time.Minute || ttl > time.Hour {
+		t.Fatalf("expected remaining global ttl, got %v err=%v", ttl, err)
+	}
+	if n := restored.InvalidateTag("group"); n != 1 {
+		t.Fatalf("expected tag restored, invalidated %d", n)
+	}
+
+	// 损坏的快照返回错误
+	data, _ := os.ReadFile(path)
+	data[len(data)/2] ^= 0xFF
+	if err = dst.LoadSnapshot(bytes.NewReader(data)); !errors.Is(err, errs.ErrSnapshotCorrupted) {
+		t.Fatalf("expected ErrSnapshotCorrupted, got %v", err)
+	}
+}
+
+func TestBoundedLRU(t *testing.T) {
+	var store Store
+	store, err := NewBounded(BoundedConfig{MaxEntries: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	for _, key := range []string{"a", "b", "c"} {
+		_ = store.Set(key, key)
+	}
+	var value string
+	// 访问 a 后 b 成为最久未使用
+	_ = store.Get("a", &value)
+	_ = store.Set("d", "d")
+
+	if err = store.Get("b", &value); !errors.Is(err, ErrEntryNotFound) {
+		t.Fatalf("expected b evicted, got %v", err)
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if err = store.Get(key, &value); err != nil || value != key {
+			t.Fatalf("unexpected result for %s: %q err=%v", key, value, err)
+		}
+	}
+	if s := store.Stats(); s.Entries != 3 || s.Evictions != 1 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+}
+
+func TestBoundedMaxBytes(t *testing.T) {
+	store, err := NewBounded(BoundedConfig{MaxBytes: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	for i := range 10 {
+		_ = store.SetBytes(fmt.Sprintf("k%d", i), make([]byte, 30))
+	}
+	if s := store.Stats(); s.Bytes > 100 || s.Entries != 3 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+}
+
+func TestBoundedTinyLFU(t *testing.T) {
+	store, err := NewBounded(BoundedConfig{MaxEntries: 100, Policy: PolicyTinyLFU})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	// 热点 key 多次访问
+	for i := range 50 {
+		key := fmt.Sprintf("hot%d", i)
+		_ = store.SetBytes(key, []byte(key))
+		for range 5 {
+			_, _ = store.GetBytes(key)
+		}
+	}
+	// 大量只访问一次的 key 不应把热点挤出
+	for i := range 1000 {
+		_ = store.SetBytes(fmt.Sprintf("scan%d", i), []byte("x"))
+	}
+
+	var hot int
+	for i := range 50 {
+		if _, err = store.GetBytes(fmt.Sprintf("hot%d", i)); err == nil {
+			hot++
+		}
+	}
+	if hot < 45 || store.Len() > 100 {
+		t.Fatalf("expected hot keys to survive scan, kept %d, len %d", hot, store.Len())
+	}
+}
+
+func TestBoundedOversize(t *testing.T) {
+	store, err := NewBounded(BoundedConfig{MaxBytes: 1000, Policy: PolicyTinyLFU})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	for i := range 20 {
+		key := fmt.Sprintf("hot%02d", i)
+		_ = store.SetBytes(key, []byte(key))
+		_, _ = store.GetBytes(key)
+	}
+	// 超过总上限的条目直接拒绝，超过主区上限的条目不会挤出主区
+	if err = store.SetBytes("huge", make([]byte, 5000)); !errors.Is(err, errs.ErrEntryTooLarge) {
+		t.Fatalf("expected ErrEntryTooLarge, got %v", err)
+	}
+	_ = store.SetBytes("large", make([]byte, 995))
+	_ = store.SetBytes("small", []byte("x"))
+	if n := store.Len(); n < 20 {
+		t.Fatalf("expected hot entries kept, len %d", n)
+	}
+}
+
+func TestBoundedTTL(t *testing.T) {
+	store, err := NewBounded(BoundedConfig{MaxEntries: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	_ = store.SetWithTTL("k", "v", 20*time.Millisecond)
+	time.Sleep(40 * time.Millisecond)
+	if _, err = store.GetBytes("k"); !errors.Is(err, ErrEntryNotFound) {
+		t.Fatalf("expected expired, got %v", err)
+	}
+}
+
+func TestTagsAndPrefix(t *testing.T) {
+	cache, err := NewCacheWithConfig(Config{CleanInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	_ = cache.SetWithTags("user:42:profile", "p", "user:42", "tenant:1")
+	_ = cache.SetWithTags("user:42:orders", "o", "user:42")
+	_ = cache.SetWithTags("user:7:profile", "p", "tenant:1")
+	_ = cache.Set("config", "c")
+
+	if n := cache.InvalidateTag("user:42"); n != 2 {
+		t.Fatalf("expected 2 invalidated, got %d", n)
+	}
+	if n := cache.InvalidateTag("tenant:1"); n != 1 {
+		t.Fatalf("expected 1 invalidated, got %d", n)
+	}
+	// 主动删除时标签索引同步清理，不依赖移除事件
+	_ = cache.SetWithTags("user:5:profile", "p", "tenant:5")
+	_ = cache.Delete("user:5:profile")
+	if tags := cache.tags.tagsOf("user:5:profile"); len(tags) != 0 {
+		t.Fatalf("expected tag index cleaned after delete, got %v", tags)
+	}
+
+	// 覆盖写入不带标签，旧标签应失效
+	_ = cache.SetWithTags("user:8:profile", "p", "tenant:2")
+	_ = cache.Set("user:8:profile", "p2")
+	if n := cache.InvalidateTag("tenant:2"); n != 0 {
+		t.Fatalf("expected overwritten key untagged, got %d", n)
+	}
+
+	// 过期后标签索引被清理
+	_ = cache.SetWithTTLAndTags("user:9:profile", "p", 20*time.Millisecond, "tenant:3")
+	time.Sleep(80 * time.Millisecond)
+	cache.tags.mu.RLock()
+	_, ok := cache.tags.keys["user:9:profile"]
+	cache.tags.mu.RUnlock()
+	if ok {
+		t.Fatal("expected tag index cleaned after expiry")
+	}
+
+	_ = cache.Set("user:1:a", 1)
+	_ = cache.Set("user:1:b", 2)
+	if n := cache.DeletePrefix("user:1:"); n != 2 {
+		t.Fatalf("expected 2 deleted by prefix, got %d", n)
+	}
+	if cache.Len() != 2 {
+		t.Fatalf("expected config and user:8:profile left, len=%d", cache.Len())
+	}
+}
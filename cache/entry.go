@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// 条目布局：flags(1) | expireAt(8) | staleUntil(8) | len(key)(2) | key | value
+//
+// BigCache v1 通过 unsafe 返回的 key 字符串指向无人引用的内存，可能被 GC 回收，
+// 因此迭代与移除回调中需要的 key 从条目自带的副本中读取
+const headerSize = 19
+
+// maxKeyLen key 的最大长度：条目与 BigCache 均以 2 字节记录 key 长度，
+// 且 BigCache 读取时 key 长度加上其 18 字节头部不能溢出 uint16
+const maxKeyLen = math.MaxUint16 - 18
+
+const (
+	flagNegative byte = 1 << iota // 负缓存标记，表示数据源中不存在该 key
+)
+
+// entryHeader 缓存值头部，时间均为 UnixNano，0 表示未设置
+type entryHeader struct {
+	flags      byte
+	expireAt   int64 // 过期时间，之后普通读取视为未命中
+	staleUntil int64 // 旧值可继续提供的截止时间，之后才真正删除
+}
+
+// newHeader 根据 ttl 生成头部，ttl<=0 时不单独过期
+func newHeader(ttl time.Duration) entryHeader {
+	var h entryHeader
+	if ttl > 0 {
+		h.expireAt = time.Now().Add(ttl).UnixNano()
+	}
+	return h
+}
+
+// deadline 条目真正可以被删除的时间，0 表示不单独过期
+func (h entryHeader) deadline() int64 {
+	if h.staleUntil > h.expireAt {
+		return h.staleUntil
+	}
+	return h.expireAt
+}
+
+// wrapValue 为值加上头部
+func wrapValue(key string, h entryHeader, value []byte) []byte {
+	buf := make([]byte, headerSize+len(key)+len(value))
+	buf[0] = h.flags
+	binary.BigEndian.PutUint64(buf[1:], uint64(h.expireAt))
+	binary.BigEndian.PutUint64(buf[9:], uint64(h.staleUntil))
+	binary.BigEndian.PutUint16(buf[17:], uint16(len(key)))
+	copy(buf[headerSize:], key)
+	copy(buf[headerSize+len(key):], value)
+	return buf
+}
+
+// unwrapValue 拆出头部与原始值
+func unwrapValue(entry []byte) (entryHeader, []byte) {
+	if len(entry) < headerSize {
+		return entryHeader{}, entry
+	}
+	keyLen := min(int(binary.BigEndian.Uint16(entry[17:])), len(entry)-headerSize)
+	return entryHeader{
+		flags:      entry[0],
+		expireAt:   int64(binary.BigEndian.Uint64(entry[1:])),
+		staleUntil: int64(binary.BigEndian.Uint64(entry[9:])),
+	}, entry[headerSize+keyLen:]
+}
+
+// entryKey 读取条目自带的 key
+func entryKey(entry []byte) string {
+	if len(entry) < headerSize {
+		return ""
+	}
+	keyLen := min(int(binary.BigEndian.Uint16(entry[17:])), len(entry)-headerSize)
+	return string(entry[headerSize : headerSize+keyLen])
+}
+
+// expired 判断给定时间点是否已过
+func expired(at, now int64) bool {
+	return at > 0 && at <= now
+}
@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/lance4117/gofuse/codec"
+	"github.com/lance4117/gofuse/errs"
+)
+
+// LoaderFunc 缓存未命中时的数据加载函数
+type LoaderFunc func(ctx context.Context) (any, error)
+
+// LoadOptions GetOrLoad 的加载策略
+type LoadOptions struct {
+	TTL         time.Duration // 加载结果的过期时间，0 表示仅受全局过期时间约束
+	StaleTTL    time.Duration // 过期后仍可返回旧值的时间窗口，期间在后台刷新；需配合 TTL 使用
+	NegativeTTL time.Duration // loader 返回 errs.ErrKeyNotFound 时的负缓存时间，0 表示不缓存
+}
+
+// GetOrLoad 命中则直接返回；未命中时调用 loader 加载并写入缓存，
+// 同一 key 的并发未命中只会触发一次 loader 调用
+func (i *Cache) GetOrLoad(ctx context.Context, key string, v any, loader LoaderFunc) error {
+	return i.GetOrLoadWithOptions(ctx, key, v, loader, i.loadOpts)
+}
+
+// GetOrLoadWithOptions 同 GetOrLoad，使用指定的加载策略
+func (i *Cache) GetOrLoadWithOptions(ctx context.Context, key string, v any, loader LoaderFunc, opts LoadOptions) error {
+	if v == nil || reflect.TypeOf(v).Kind() != reflect.Ptr {
+		return errs.ErrNeedPointer
+	}
+	bytes, err := i.loadBytes(ctx, key, func(ctx context.Context) ([]byte, error) {
+		value, err := loader(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return codec.MPMarshal(value)
+	}, opts)
+	if err != nil {
+		return err
+	}
+	return codec.MPUnmarshal(bytes, v)
+}
+
+// loadBytes 字节层面的加载流程，供 Cache 与 Typed 共用
+func (i *Cache) loadBytes(ctx context.Context, key string, loader func(ctx context.Context) ([]byte, error), opts LoadOptions) ([]byte, error) {
+	if entry, err := i.BigCache.Get(key); err == nil {
+		h, value := unwrapValue(entry)
+		now := time.Now().UnixNano()
+		switch {
+		case !expired(h.expireAt, now):
+			i.hits.Add(1)
+			if h.flags&flagNegative != 0 {
+				return nil, errs.ErrKeyNotFound
+			}
+			return value, nil
+		case h.flags&flagNegative == 0 && !expired(h.staleUntil, now) && h.staleUntil > 0:
+			// 返回旧值，后台刷新
+			i.hits.Add(1)
+			i.group.DoChan(key, func() (any, error) {
+				return i.load(context.WithoutCancel(ctx), key, loader, opts)
+			})
+			return value, nil
+		}
+	}
+
+	i.misses.Add(1)
+	ch := i.group.DoChan(key, func() (any, error) {
+		// 共享的加载不随单个调用方取消
+		return i.load(context.WithoutCancel(ctx), key, loader, opts)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.([]byte), nil
+	}
+}
+
+// load 调用 loader 并按策略写入缓存
+func (i *Cache) load(ctx context.Context, key string, loader func(ctx context.Context) ([]byte, error), opts LoadOptions) ([]byte, error) {
+	value, err := loader(ctx)
+	now := time.Now()
+	if err != nil {
+		if opts.NegativeTTL > 0 && errors.Is(err, errs.ErrKeyNotFound) {
+			_ = i.setEntry(key, entryHeader{
+				flags:    flagNegative,
+				expireAt: now.Add(opts.NegativeTTL).UnixNano(),
+			}, nil, nil)
+		}
+		return nil, err
+	}
+
+	var h entryHeader
+	if opts.TTL > 0 {
+		h.expireAt = now.Add(opts.TTL).UnixNano()
+		if opts.StaleTTL > 0 {
+			h.staleUntil = now.Add(opts.TTL + opts.StaleTTL).UnixNano()
+		}
+	}
+	if err = i.setEntry(key, h, value, nil); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/lance4117/gofuse/codec"
+	"github.com/lance4117/gofuse/eventbus"
+	"github.com/lance4117/gofuse/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+// Invalidation L1 失效通知
+type Invalidation struct {
+	Origin string // 发出通知的实例标识
+	Key    string // 需要失效的key
+}
+
+// Notifier 失效通知的传输通道
+type Notifier interface {
+	// Publish 广播失效通知
+	Publish(msg Invalidation) error
+	// Subscribe 订阅失效通知，返回取消订阅函数
+	Subscribe(fn func(msg Invalidation)) (func(), error)
+}
+
+// RedisNotifier 基于 Redis pub/sub 的失效通知
+type RedisNotifier struct {
+	cli     *redis.Client
+	channel string
+}
+
+// NewRedisNotifier 创建基于 Redis pub/sub 的失效通知，cli 可复用 rediskv.RedisStore.RedisCli
+func NewRedisNotifier(cli *redis.Client, channel string) *RedisNotifier {
+	return &RedisNotifier{cli: cli, channel: channel}
+}
+
+// Publish 广播失效通知
+func (n *RedisNotifier) Publish(msg Invalidation) error {
+	bytes, err := codec.MPMarshal(msg)
+	if err != nil {
+		return err
+	}
+	return n.cli.Publish(context.Background(), n.channel, bytes).Err()
+}
+
+// Subscribe 订阅失效通知，返回取消订阅函数
+func (n *RedisNotifier) Subscribe(fn func(msg Invalidation)) (func(), error) {
+	ctx := context.Background()
+	ps := n.cli.Subscribe(ctx, n.channel)
+	// 等待订阅确认，尽早暴露连接错误
+	if _, err := ps.Receive(ctx); err != nil {
+		_ = ps.Close()
+		return nil, err
+	}
+	go func() {
+		for m := range ps.Channel() {
+			var msg Invalidation
+			if err := codec.MPUnmarshal([]byte(m.Payload), &msg); err != nil {
+				logger.Errorf("redis notifier decode channel=%s: %v", n.channel, err)
+				continue
+			}
+			fn(msg)
+		}
+	}()
+	return func() { _ = ps.Close() }, nil
+}
+
+// EventBusNotifier 基于 eventbus 的失效通知，适用于同进程内的多个缓存实例
+type EventBusNotifier struct {
+	bus   *eventbus.EventBus
+	topic eventbus.Topic
+}
+
+// NewEventBusNotifier 创建基于 eventbus 的失效通知
+func NewEventBusNotifier(bus *eventbus.EventBus, topic eventbus.Topic) *EventBusNotifier {
+	return &EventBusNotifier{bus: bus, topic: topic}
+}
+
+// Publish 广播失效通知
+func (n *EventBusNotifier) Publish(msg Invalidation) error {
+	n.bus.Publish(n.topic, msg)
+	return nil
+}
+
+// Subscribe 订阅失效通知，返回取消订阅函数
+func (n *EventBusNotifier) Subscribe(fn func(msg Invalidation)) (func(), error) {
+	return n.bus.Subscribe(n.topic, func(e *eventbus.Event) {
+		if msg, ok := e.Data.(Invalidation); ok {
+			fn(msg)
+		}
+	}), nil
+}
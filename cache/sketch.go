@@ -0,0 +1,67 @@
+package cache
+
+// cmSketch 计数最小草图（Count-Min Sketch），用于估算 key 的近期访问频率，
+// 计数上限为 15，累计次数达到阈值后整体减半以淘汰历史热度
+type cmSketch struct {
+	rows      [4][]uint8
+	mask      uint64
+	additions int
+	resetAt   int
+}
+
+func newCMSketch(capacity int) *cmSketch {
+	width := 64
+	for width < capacity {
+		width <<= 1
+	}
+	s := &cmSketch{mask: uint64(width - 1), resetAt: capacity * 10}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// indexes 对同一个哈希派生出每一行的下标
+func (s *cmSketch) indexes(key string) [4]uint64 {
+	// FNV-1a 64
+	var h uint64 = 14695981039346656037
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+	h1, h2 := h&0xffffffff, h>>32
+	var idx [4]uint64
+	for i := range idx {
+		idx[i] = (h1 + uint64(i)*h2) & s.mask
+	}
+	return idx
+}
+
+func (s *cmSketch) increment(key string) {
+	for i, idx := range s.indexes(key) {
+		if s.rows[i][idx] < 15 {
+			s.rows[i][idx]++
+		}
+	}
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.reset()
+	}
+}
+
+func (s *cmSketch) estimate(key string) uint8 {
+	var m uint8 = 15
+	for i, idx := range s.indexes(key) {
+		m = min(m, s.rows[i][idx])
+	}
+	return m
+}
+
+func (s *cmSketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
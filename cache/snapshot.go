@@ -0,0 +1,213 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lance4117/gofuse/errs"
+)
+
+// 快照格式：
+//
+//	magic(4) | version(1) | entry... | 0x00 | crc32(4)
+//	entry = 0x01 | uvarint(len(key)) | key | expireAt(8) | staleUntil(8) | uvarint(len(value)) | value | tags
+//	tags  = uvarint(n) | (uvarint(len(tag)) | tag)...，版本 1 没有 tags
+//
+// 过期时间为绝对时间（UnixNano），未单独设置 TTL 的条目按全局过期时间的剩余部分保存，
+// 加载时据此丢弃已过期条目，剩余 TTL 保持不变；crc32 覆盖其之前的全部字节。
+const (
+	snapshotMagic   = "GFCS"
+	snapshotVersion = 2
+)
+
+// SaveSnapshot 将当前未过期的条目写入 w
+func (i *Cache) SaveSnapshot(w io.Writer) error {
+	crc := crc32.NewIEEE()
+	bw := bufio.NewWriter(io.MultiWriter(w, crc))
+
+	if _, err := bw.WriteString(snapshotMagic); err != nil {
+		return err
+	}
+	if err := bw.WriteByte(snapshotVersion); err != nil {
+		return err
+	}
+
+	now := time.Now().UnixNano()
+	buf := make([]byte, binary.MaxVarintLen64)
+	it := i.BigCache.Iterator()
+	for it.SetNext() {
+		info, err := it.Value()
+		if err != nil {
+			// 迭代期间条目被删除
+			continue
+		}
+		entry := info.Value()
+		h, value := unwrapValue(entry)
+		if h.flags&flagNegative != 0 || expired(h.deadline(), now) {
+			continue
+		}
+		if h.expireAt == 0 && i.life < noExpiry {
+			// 全局过期时间从写入 BigCache 时开始计算
+			h.expireAt = time.Unix(int64(info.Timestamp()), 0).Add(i.life).UnixNano()
+			if expired(h.expireAt, now) {
+				continue
+			}
+		}
+		key := entryKey(entry)
+		tags := i.tags.tagsOf(key)
+		_ = bw.WriteByte(1)
+		writeChunk(bw, buf, key)
+		_ = binary.Write(bw, binary.BigEndian, [2]int64{h.expireAt, h.staleUntil})
+		writeChunk(bw, buf, string(value))
+		_, _ = bw.Write(buf[:binary.PutUvarint(buf, uint64(len(tags)))])
+		for _, tag := range tags {
+			writeChunk(bw, buf, tag)
+		}
+	}
+	if err := bw.WriteByte(0); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.BigEndian, crc.Sum32())
+}
+
+// LoadSnapshot 从 r 读取快照并写入缓存，已过期的条目被丢弃；
+// 快照损坏时返回 errs.ErrSnapshotCorrupted，且不写入任何条目
+func (i *Cache) LoadSnapshot(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if len(data) < len(snapshotMagic)+1+1+crc32.Size || string(data[:len(snapshotMagic)]) != snapshotMagic {
+		return errs.ErrSnapshotCorrupted
+	}
+	body, sum := data[:len(data)-crc32.Size], data[len(data)-crc32.Size:]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(sum) {
+		return errs.ErrSnapshotCorrupted
+	}
+	version := body[len(snapshotMagic)]
+	if version < 1 || version > snapshotVersion {
+		return errs.ErrSnapshotVersion
+	}
+
+	type item struct {
+		key   string
+		h     entryHeader
+		value []byte
+		tags  []string
+	}
+	var items []item
+	br := bytes.NewReader(body[len(snapshotMagic)+1:])
+	for {
+		marker, err := br.ReadByte()
+		if err != nil {
+			return errs.ErrSnapshotCorrupted
+		}
+		if marker == 0 {
+			break
+		}
+		key, err := readChunk(br)
+		if err != nil {
+			return err
+		}
+		var times [2]int64
+		if err = binary.Read(br, binary.BigEndian, &times); err != nil {
+			return errs.ErrSnapshotCorrupted
+		}
+		value, err := readChunk(br)
+		if err != nil {
+			return err
+		}
+		var tags []string
+		if version >= 2 {
+			n, err := binary.ReadUvarint(br)
+			if err != nil || n > uint64(br.Len()) {
+				return errs.ErrSnapshotCorrupted
+			}
+			for range n {
+				tag, err := readChunk(br)
+				if err != nil {
+					return err
+				}
+				tags = append(tags, string(tag))
+			}
+		}
+		items = append(items, item{
+			key:   string(key),
+			h:     entryHeader{expireAt: times[0], staleUntil: times[1]},
+			value: value,
+			tags:  tags,
+		})
+	}
+	if br.Len() != 0 {
+		return errs.ErrSnapshotCorrupted
+	}
+
+	now := time.Now().UnixNano()
+	for _, it := range items {
+		if expired(it.h.deadline(), now) {
+			continue
+		}
+		if err = i.setEntry(it.key, it.h, it.value, it.tags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveSnapshotFile 将快照写入指定文件，先写临时文件再原子替换
+func (i *Cache) SaveSnapshotFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err = i.SaveSnapshot(tmp); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadSnapshotFile 从指定文件加载快照
+func (i *Cache) LoadSnapshotFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return i.LoadSnapshot(f)
+}
+
+// writeChunk 写入 uvarint 长度前缀的数据块，错误由最后的 Flush 返回
+func writeChunk(bw *bufio.Writer, buf []byte, chunk string) {
+	_, _ = bw.Write(buf[:binary.PutUvarint(buf, uint64(len(chunk)))])
+	_, _ = bw.WriteString(chunk)
+}
+
+// readChunk 读取 uvarint 长度前缀的数据块
+func readChunk(br *bytes.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(br)
+	if err != nil || n > uint64(br.Len()) {
+		return nil, errs.ErrSnapshotCorrupted
+	}
+	chunk := make([]byte, n)
+	_, _ = io.ReadFull(br, chunk)
+	return chunk, nil
+}
@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/allegro/bigcache"
+	"github.com/lance4117/gofuse/logger"
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// EvictReason 条目被移除的原因
+type EvictReason int
+
+const (
+	EvictExpired EvictReason = iota + 1 // 过期（全局过期窗口或单独的 TTL）
+	EvictNoSpace                        // 空间不足，最旧的条目被挤出
+	EvictDeleted                        // 被主动删除
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictExpired:
+		return "expired"
+	case EvictNoSpace:
+		return "no_space"
+	case EvictDeleted:
+		return "deleted"
+	default:
+		return "unknown"
+	}
+}
+
+// Stats 缓存统计信息
+type Stats struct {
+	Hits       int64 // 命中次数
+	Misses     int64 // 未命中次数（含已过期）
+	Collisions int64 // BigCache 哈希冲突次数
+	Evictions  int64 // 被移除的条目数
+	Entries    int   // 当前条目数
+	Bytes      int   // 当前条目占用的字节数，仅 Bounded 统计
+	Capacity   int   // BigCache 已分配的字节数，仅 Cache 统计，包含尚未回收的空间
+}
+
+// HitRatio 命中率，无访问时返回 0
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// evictEvent 移除事件
+type evictEvent struct {
+	key       string
+	reason    EvictReason
+	checkLive bool // 队列淘汰可能是被覆盖的旧条目，需要确认 key 已不存在
+}
+
+// Stats 返回缓存统计信息
+func (i *Cache) Stats() Stats {
+	bs := i.BigCache.Stats()
+	return Stats{
+		Hits:       i.hits.Load(),
+		Misses:     i.misses.Load(),
+		Collisions: bs.Collisions,
+		Evictions:  i.evictions.Load(),
+		Entries:    i.BigCache.Len(),
+		Capacity:   i.BigCache.Capacity(),
+	}
+}
+
+// OnEvict 注册条目被移除时的回调，回调在独立的 goroutine 中串行执行，可安全地访问缓存
+func (i *Cache) OnEvict(fn func(key string, reason EvictReason)) {
+	i.evictMu.Lock()
+	i.onEvict = append(i.onEvict, fn)
+	i.evictMu.Unlock()
+}
+
+// onRemove BigCache 移除回调，在分片锁内执行，只做投递
+func (i *Cache) onRemove(_ string, entry []byte, reason bigcache.RemoveReason) {
+	h, _ := unwrapValue(entry)
+	key := entryKey(entry)
+	if h.flags&flagNegative != 0 {
+		return
+	}
+	ev := evictEvent{key: key}
+	switch reason {
+	case bigcache.Deleted:
+		ev.reason = EvictDeleted
+		if expired(h.deadline(), time.Now().UnixNano()) {
+			ev.reason = EvictExpired
+		}
+	case bigcache.NoSpace:
+		ev.reason, ev.checkLive = EvictNoSpace, true
+	default:
+		ev.reason, ev.checkLive = EvictExpired, true
+	}
+	select {
+	case i.evictCh <- ev:
+	default:
+		logger.Warnf("cache evict event dropped key=%s reason=%s", key, ev.reason)
+	}
+}
+
+// dispatchEvictions 串行分发移除事件
+func (i *Cache) dispatchEvictions() {
+	for {
+		select {
+		case <-i.stop:
+			return
+		case ev := <-i.evictCh:
+			// key 已被重新写入时，移除的只是旧条目；主动删除时标签已同步清理
+			if ev.checkLive && !i.dropEvicted(ev.key) {
+				continue
+			}
+			i.evictions.Add(1)
+			i.evictMu.RLock()
+			fns := i.onEvict
+			i.evictMu.RUnlock()
+			for _, fn := range fns {
+				fn(ev.key, ev.reason)
+			}
+		}
+	}
+}
+
+// dropEvicted 在持有 key 锁的情况下确认 key 已不存在并清理标签，key 已被重新写入时返回 false
+func (i *Cache) dropEvicted(key string) bool {
+	l := i.locks.lock(key)
+	defer l.Unlock()
+	if _, err := i.BigCache.Get(key); err == nil {
+		return false
+	}
+	i.tags.remove(key)
+	return true
+}
+
+// StatsCollector 缓存统计采集器，实现 monitor.Collector
+type StatsCollector struct {
+	name  string
+	cache *Cache
+}
+
+// NewStatsCollector 初始化缓存统计采集器，name 作为列名前缀
+func NewStatsCollector(name string, cache *Cache) *StatsCollector {
+	return &StatsCollector{name: name, cache: cache}
+}
+
+func (c *StatsCollector) Names() []string {
+	return []string{
+		c.name + "Hits", c.name + "Misses", c.name + "HitRatio(%)",
+		c.name + "Evictions", c.name + "Entries", c.name + "Capacity(KB)",
+	}
+}
+
+func (c *StatsCollector) Collect(_ *process.Process, _ time.Time) ([]string, error) {
+	s := c.cache.Stats()
+	return []string{
+		fmt.Sprintf("%d", s.Hits),
+		fmt.Sprintf("%d", s.Misses),
+		fmt.Sprintf("%.2f", s.HitRatio()*100),
+		fmt.Sprintf("%d", s.Evictions),
+		fmt.Sprintf("%d", s.Entries),
+		fmt.Sprintf("%d", s.Capacity/1024),
+	}, nil
+}
+
+// WritePrometheus 以 Prometheus 文本格式输出统计信息，name 作为 cache 标签
+func (i *Cache) WritePrometheus(w io.Writer, name string) error {
+	s := i.Stats()
+	metrics := []struct {
+		name, typ, help string
+		value           float64
+	}{
+		{"gofuse_cache_hits_total", "counter", "Number of cache hits.", float64(s.Hits)},
+		{"gofuse_cache_misses_total", "counter", "Number of cache misses.", float64(s.Misses)},
+		{"gofuse_cache_collisions_total", "counter", "Number of key collisions.", float64(s.Collisions)},
+		{"gofuse_cache_evictions_total", "counter", "Number of evicted entries.", float64(s.Evictions)},
+		{"gofuse_cache_entries", "gauge", "Number of entries in the cache.", float64(s.Entries)},
+		{"gofuse_cache_capacity_bytes", "gauge", "Bytes allocated by the cache.", float64(s.Capacity)},
+		{"gofuse_cache_hit_ratio", "gauge", "Ratio of hits to total lookups.", s.HitRatio()},
+	}
+	for _, m := range metrics {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s{cache=%q} %g\n",
+			m.name, m.help, m.name, m.typ, m.name, name, m.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/allegro/bigcache"
+)
+
+// ErrEntryNotFound 未命中时返回的错误，各 Store 实现统一返回该错误
+var ErrEntryNotFound = bigcache.ErrEntryNotFound
+
+// Store 缓存引擎的通用接口，调用方可在不改动代码的情况下切换淘汰策略
+type Store interface {
+	// Set 将指定key存储序列化数据
+	Set(key string, value any) error
+	// SetWithTTL 将指定key存储序列化数据，并单独指定过期时间
+	SetWithTTL(key string, value any, ttl time.Duration) error
+	// SetBytes 将指定key存储原始字节
+	SetBytes(key string, value []byte) error
+	// SetBytesWithTTL 将指定key存储原始字节，并单独指定过期时间
+	SetBytesWithTTL(key string, value []byte, ttl time.Duration) error
+	// Get 根据key 将 v指针,返回对应数据
+	Get(key string, v any) error
+	// GetBytes 根据key 返回原始字节
+	GetBytes(key string) ([]byte, error)
+	// Delete 删除
+	Delete(key string) error
+	// Len 当前缓存长度
+	Len() int
+	// Stats 缓存统计信息
+	Stats() Stats
+	// Reset 重置缓存
+	Reset() error
+	// Close 关闭缓存
+	Close() error
+}
+
+var (
+	_ Store = (*Cache)(nil)
+	_ Store = (*Bounded)(nil)
+)
@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// tagIndex 标签索引，只记录带标签的 key；条目被覆盖或主动删除时同步更新，被 BigCache 淘汰时由移除事件清理
+type tagIndex struct {
+	mu   sync.RWMutex
+	tags map[string]map[string]struct{} // tag -> keys
+	keys map[string][]string            // key -> tags
+}
+
+// set 覆盖 key 的标签，tags 为空时清除
+func (t *tagIndex) set(key string, tags []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(tags) == 0 && len(t.keys) == 0 {
+		return
+	}
+	t.removeLocked(key)
+	if len(tags) == 0 {
+		return
+	}
+	if t.tags == nil {
+		t.tags = make(map[string]map[string]struct{})
+		t.keys = make(map[string][]string)
+	}
+	for _, tag := range tags {
+		keys, ok := t.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			t.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	t.keys[key] = tags
+}
+
+func (t *tagIndex) remove(key string) {
+	t.mu.Lock()
+	t.removeLocked(key)
+	t.mu.Unlock()
+}
+
+func (t *tagIndex) removeLocked(key string) {
+	for _, tag := range t.keys[key] {
+		if keys := t.tags[tag]; keys != nil {
+			delete(keys, key)
+			if len(keys) == 0 {
+				delete(t.tags, tag)
+			}
+		}
+	}
+	delete(t.keys, key)
+}
+
+// tagsOf 返回 key 的标签
+func (t *tagIndex) tagsOf(key string) []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.keys[key]
+}
+
+// keysOf 返回带有指定标签的 key
+func (t *tagIndex) keysOf(tag string) []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	out := make([]string, 0, len(t.tags[tag]))
+	for key := range t.tags[tag] {
+		out = append(out, key)
+	}
+	return out
+}
+
+func (t *tagIndex) reset() {
+	t.mu.Lock()
+	t.tags, t.keys = nil, nil
+	t.mu.Unlock()
+}
+
+// SetWithTags 将指定key存储序列化数据，并打上标签，便于通过 InvalidateTag 批量失效
+func (i *Cache) SetWithTags(key string, value any, tags ...string) error {
+	return i.SetWithTTLAndTags(key, value, 0, tags...)
+}
+
+// SetWithTTLAndTags 同 SetWithTags，并单独指定过期时间
+func (i *Cache) SetWithTTLAndTags(key string, value any, ttl time.Duration, tags ...string) error {
+	return i.set(key, value, ttl, tags)
+}
+
+// InvalidateTag 删除带有指定标签的所有key，返回删除的数量
+func (i *Cache) InvalidateTag(tag string) int {
+	var n int
+	for _, key := range i.tags.keysOf(tag) {
+		if i.Delete(key) == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// DeletePrefix 删除以 prefix 开头的所有key，返回删除的数量；
+// 需要遍历全部条目，不宜在热路径上频繁调用
+func (i *Cache) DeletePrefix(prefix string) int {
+	var keys []string
+	it := i.BigCache.Iterator()
+	for it.SetNext() {
+		info, err := it.Value()
+		if err != nil {
+			continue
+		}
+		if key := entryKey(info.Value()); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	var n int
+	for _, key := range keys {
+		if i.Delete(key) == nil {
+			n++
+		}
+	}
+	return n
+}
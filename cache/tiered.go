@@ -0,0 +1,219 @@
+package cache
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/lance4117/gofuse/codec"
+	"github.com/lance4117/gofuse/errs"
+	"github.com/lance4117/gofuse/gen"
+	"github.com/lance4117/gofuse/logger"
+	"github.com/lance4117/gofuse/store/kvs"
+)
+
+// WriteMode 二级缓存写入模式
+type WriteMode int
+
+const (
+	WriteThrough WriteMode = iota // 同步写入 L2 后再写 L1
+	WriteBehind                   // 先写 L1，异步写入 L2
+)
+
+// TieredOptions 二级缓存配置
+type TieredOptions struct {
+	L1TTL     time.Duration // 条目在 L1 中的过期时间，0 表示仅受 L1 全局过期时间约束
+	Mode      WriteMode     // 写入模式，默认 WriteThrough
+	QueueSize int           // WriteBehind 模式下的异步写队列长度，默认 1024
+	Notifier  Notifier      // 可选，多实例之间的 L1 失效通知
+}
+
+// tieredOp WriteBehind 模式下的异步写操作
+type tieredOp struct {
+	key   string
+	value []byte
+	del   bool
+}
+
+// Tiered 二级缓存，L1 为任意进程内 Store（如 Cache、Bounded），L2 为任意 kvs.KVStore（如 Redis、Pebble）
+type Tiered struct {
+	l1   Store
+	l2   kvs.KVStore
+	opts TieredOptions
+	id   string // 实例标识，用于忽略自身发出的失效通知
+
+	queue       chan tieredOp
+	queueMu     sync.RWMutex // 保护 closed 与向 queue 发送，避免 Close 后写入已关闭的队列
+	closed      bool
+	done        chan struct{}
+	unsubscribe func()
+	closeOnce   sync.Once
+}
+
+// NewTiered 创建二级缓存，L1、L2 的生命周期由调用方管理
+func NewTiered(l1 Store, l2 kvs.KVStore, opts TieredOptions) (*Tiered, error) {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1024
+	}
+	t := &Tiered{
+		l1:   l1,
+		l2:   l2,
+		opts: opts,
+		id:   gen.ShortID(),
+	}
+
+	if opts.Notifier != nil {
+		unsubscribe, err := opts.Notifier.Subscribe(t.onInvalidate)
+		if err != nil {
+			return nil, err
+		}
+		t.unsubscribe = unsubscribe
+	}
+
+	if opts.Mode == WriteBehind {
+		t.queue = make(chan tieredOp, opts.QueueSize)
+		t.done = make(chan struct{})
+		go t.writeBehind()
+	}
+	return t, nil
+}
+
+// Set 将指定key存储序列化数据
+func (t *Tiered) Set(key string, value any) error {
+	marshal, err := codec.MPMarshal(value)
+	if err != nil {
+		return err
+	}
+	return t.SetBytes(key, marshal)
+}
+
+// SetBytes 将指定key存储原始字节
+func (t *Tiered) SetBytes(key string, value []byte) error {
+	if t.opts.Mode == WriteBehind {
+		t.queueMu.RLock()
+		defer t.queueMu.RUnlock()
+		if t.closed {
+			return errs.ErrCacheClosed
+		}
+		if err := t.l1.SetBytesWithTTL(key, value, t.opts.L1TTL); err != nil {
+			return err
+		}
+		t.queue <- tieredOp{key: key, value: value}
+		return nil
+	}
+
+	if err := t.l2.Put(key, value); err != nil {
+		return err
+	}
+	t.notify(key)
+	return t.l1.SetBytesWithTTL(key, value, t.opts.L1TTL)
+}
+
+// Get 根据key 将 v指针,返回对应数据，两级都未命中时返回 errs.ErrKeyNotFound
+func (t *Tiered) Get(key string, v any) error {
+	if v == nil || reflect.TypeOf(v).Kind() != reflect.Ptr {
+		return errs.ErrNeedPointer
+	}
+	bytes, err := t.GetBytes(key)
+	if err != nil {
+		return err
+	}
+	return codec.MPUnmarshal(bytes, v)
+}
+
+// GetBytes 先读 L1，未命中时读取 L2 并回填 L1
+func (t *Tiered) GetBytes(key string) ([]byte, error) {
+	value, err := t.l1.GetBytes(key)
+	if err == nil {
+		return value, nil
+	}
+	if !errors.Is(err, ErrEntryNotFound) {
+		return nil, err
+	}
+
+	value, err = t.l2.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if err = t.l1.SetBytesWithTTL(key, value, t.opts.L1TTL); err != nil {
+		logger.Errorf("tiered cache fill l1 key=%s: %v", key, err)
+	}
+	return value, nil
+}
+
+// Delete 同时删除两级缓存中的key
+func (t *Tiered) Delete(key string) error {
+	if t.opts.Mode == WriteBehind {
+		t.queueMu.RLock()
+		defer t.queueMu.RUnlock()
+		if t.closed {
+			return errs.ErrCacheClosed
+		}
+	}
+	if err := t.l1.Delete(key); err != nil && !errors.Is(err, ErrEntryNotFound) {
+		return err
+	}
+	if t.opts.Mode == WriteBehind {
+		t.queue <- tieredOp{key: key, del: true}
+		return nil
+	}
+	if err := t.l2.Del(key); err != nil {
+		return err
+	}
+	t.notify(key)
+	return nil
+}
+
+// Close 停止接收失效通知，并等待 WriteBehind 队列写完；之后 WriteBehind 模式的写入返回 errs.ErrCacheClosed
+func (t *Tiered) Close() error {
+	t.closeOnce.Do(func() {
+		if t.unsubscribe != nil {
+			t.unsubscribe()
+		}
+		if t.queue != nil {
+			t.queueMu.Lock()
+			t.closed = true
+			close(t.queue)
+			t.queueMu.Unlock()
+			<-t.done
+		}
+	})
+	return nil
+}
+
+// writeBehind 异步写入 L2
+func (t *Tiered) writeBehind() {
+	defer close(t.done)
+	for op := range t.queue {
+		var err error
+		if op.del {
+			err = t.l2.Del(op.key)
+		} else {
+			err = t.l2.Put(op.key, op.value)
+		}
+		if err != nil {
+			logger.Errorf("tiered cache write-behind key=%s: %v", op.key, err)
+			continue
+		}
+		t.notify(op.key)
+	}
+}
+
+// notify 通知其他实例失效 L1
+func (t *Tiered) notify(key string) {
+	if t.opts.Notifier == nil {
+		return
+	}
+	if err := t.opts.Notifier.Publish(Invalidation{Origin: t.id, Key: key}); err != nil {
+		logger.Errorf("tiered cache notify key=%s: %v", key, err)
+	}
+}
+
+// onInvalidate 收到其他实例的失效通知后删除 L1 中的key
+func (t *Tiered) onInvalidate(msg Invalidation) {
+	if msg.Origin == t.id {
+		return
+	}
+	_ = t.l1.Delete(msg.Key)
+}
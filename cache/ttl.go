@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// NoTTL 表示该 key 未设置单独的过期时间，仅受全局过期窗口约束
+const NoTTL time.Duration = -1
+
+// expiryItem 过期索引中的一项
+type expiryItem struct {
+	key      string
+	expireAt int64
+	index    int // 在堆中的位置
+}
+
+// expiryHeap 按过期时间排序的小顶堆
+type expiryHeap []*expiryItem
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expireAt < h[j].expireAt }
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *expiryHeap) Push(x any) {
+	item := x.(*expiryItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+func (h *expiryHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
+
+// expiryIndex 二级过期索引，仅作为后台清理的提示，每个 key 最多一项；
+// 真实的过期时间以值头为准，弹出的索引项会在删除前校验
+type expiryIndex struct {
+	mu    sync.Mutex
+	items expiryHeap
+	keys  map[string]*expiryItem
+}
+
+// add 登记或更新 key 的过期时间
+func (idx *expiryIndex) add(key string, expireAt int64) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if item, ok := idx.keys[key]; ok {
+		item.expireAt = expireAt
+		heap.Fix(&idx.items, item.index)
+		return
+	}
+	if idx.keys == nil {
+		idx.keys = make(map[string]*expiryItem)
+	}
+	item := &expiryItem{key: key, expireAt: expireAt}
+	heap.Push(&idx.items, item)
+	idx.keys[key] = item
+}
+
+// remove 删除 key 的索引项
+func (idx *expiryIndex) remove(key string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if item, ok := idx.keys[key]; ok {
+		heap.Remove(&idx.items, item.index)
+		delete(idx.keys, key)
+	}
+}
+
+// popExpired 弹出所有到期的索引项
+func (idx *expiryIndex) popExpired(now int64) []*expiryItem {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	var out []*expiryItem
+	for idx.items.Len() > 0 && expired(idx.items[0].expireAt, now) {
+		item := heap.Pop(&idx.items).(*expiryItem)
+		delete(idx.keys, item.key)
+		out = append(out, item)
+	}
+	return out
+}
+
+func (idx *expiryIndex) reset() {
+	idx.mu.Lock()
+	idx.items, idx.keys = nil, nil
+	idx.mu.Unlock()
+}
+
+// keyLocks 分段锁，串行化同一 key 的写入与过期删除，避免误删刚写入的新值
+type keyLocks [256]sync.Mutex
+
+func (l *keyLocks) lock(key string) *sync.Mutex {
+	// FNV-1a
+	var h uint32 = 2166136261
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	m := &l[h%uint32(len(l))]
+	m.Lock()
+	return m
+}
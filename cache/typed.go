@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/allegro/bigcache"
+	"github.com/lance4117/gofuse/codec"
+)
+
+// Typed 泛型缓存，基于 Cache，通过可插拔的 codec.Codec 完成序列化，免去指针与反射检查
+type Typed[T any] struct {
+	cache *Cache
+	codec codec.Codec[T]
+}
+
+// NewTyped 初始化带过期时间的泛型缓存,0为不过期; c 为空时默认使用 MessagePack
+func NewTyped[T any](expTime time.Duration, c codec.Codec[T]) (*Typed[T], error) {
+	cache, err := NewCache(expTime)
+	if err != nil {
+		return nil, err
+	}
+	return WrapTyped(cache, c), nil
+}
+
+// WrapTyped 基于已有的 Cache 创建泛型缓存，多个 Typed 可共享同一个 Cache
+func WrapTyped[T any](cache *Cache, c codec.Codec[T]) *Typed[T] {
+	if c == nil {
+		c = codec.Msgpack[T]()
+	}
+	return &Typed[T]{cache: cache, codec: c}
+}
+
+// Get 根据key 返回对应数据，第二个返回值表示是否命中
+func (t *Typed[T]) Get(key string) (T, bool, error) {
+	var zero T
+	bytes, err := t.cache.GetBytes(key)
+	if err != nil {
+		if errors.Is(err, bigcache.ErrEntryNotFound) {
+			return zero, false, nil
+		}
+		return zero, false, err
+	}
+	value, err := t.codec.Unmarshal(bytes)
+	if err != nil {
+		return zero, false, err
+	}
+	return value, true, nil
+}
+
+// Set 将指定key存储序列化数据
+func (t *Typed[T]) Set(key string, value T) error {
+	bytes, err := t.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return t.cache.SetBytes(key, bytes)
+}
+
+// SetWithTTL 将指定key存储序列化数据，并单独指定过期时间
+func (t *Typed[T]) SetWithTTL(key string, value T, ttl time.Duration) error {
+	bytes, err := t.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return t.cache.SetBytesWithTTL(key, bytes, ttl)
+}
+
+// TTL 返回key 剩余的过期时间
+func (t *Typed[T]) TTL(key string) (time.Duration, error) {
+	return t.cache.TTL(key)
+}
+
+// GetOrLoad 命中则直接返回，未命中时调用 loader 加载并写入缓存，
+// 同一 key 的并发未命中只会触发一次 loader 调用，加载策略沿用底层 Cache 的配置
+func (t *Typed[T]) GetOrLoad(key string, loader func() (T, error)) (T, error) {
+	var zero T
+	bytes, err := t.cache.loadBytes(context.Background(), key, func(context.Context) ([]byte, error) {
+		value, err := loader()
+		if err != nil {
+			return nil, err
+		}
+		return t.codec.Marshal(value)
+	}, t.cache.loadOpts)
+	if err != nil {
+		return zero, err
+	}
+	return t.codec.Unmarshal(bytes)
+}
+
+// Delete 删除
+func (t *Typed[T]) Delete(key string) error {
+	return t.cache.Delete(key)
+}
+
+// Len 当前缓存长度
+func (t *Typed[T]) Len() int {
+	return t.cache.Len()
+}
+
+// Cache 返回底层的 Cache
+func (t *Typed[T]) Cache() *Cache {
+	return t.cache
+}
+
+// Close 关闭缓存
+func (t *Typed[T]) Close() error {
+	return t.cache.Close()
+}
@@ -0,0 +1,81 @@
+package codec
+
+import (
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec 泛型编解码接口，用于在类型 T 与 []byte 之间转换
+type Codec[T any] interface {
+	Marshal(value T) ([]byte, error)
+	Unmarshal(bytes []byte) (T, error)
+}
+
+// MsgpackCodec 基于 MessagePack 的编解码器
+type MsgpackCodec[T any] struct{}
+
+// Msgpack 返回 MessagePack 编解码器
+func Msgpack[T any]() Codec[T] {
+	return MsgpackCodec[T]{}
+}
+
+func (MsgpackCodec[T]) Marshal(value T) ([]byte, error) {
+	return MPMarshal(value)
+}
+
+func (MsgpackCodec[T]) Unmarshal(bytes []byte) (T, error) {
+	return MPUnmarshalTo[T](bytes)
+}
+
+// JSONCodec 基于 JSON 的编解码器
+type JSONCodec[T any] struct{}
+
+// JSON 返回 JSON 编解码器
+func JSON[T any]() Codec[T] {
+	return JSONCodec[T]{}
+}
+
+func (JSONCodec[T]) Marshal(value T) ([]byte, error) {
+	return JSONMarshal(value)
+}
+
+func (JSONCodec[T]) Unmarshal(bytes []byte) (T, error) {
+	return JSONUnmarshalTo[T](bytes)
+}
+
+// ProtoCodec 基于 protobuf 的编解码器，T 为生成的消息指针类型，如 *pb.User
+type ProtoCodec[T proto.Message] struct{}
+
+// Proto 返回 protobuf 编解码器
+func Proto[T proto.Message]() Codec[T] {
+	return ProtoCodec[T]{}
+}
+
+func (ProtoCodec[T]) Marshal(value T) ([]byte, error) {
+	return proto.Marshal(value)
+}
+
+func (ProtoCodec[T]) Unmarshal(bytes []byte) (T, error) {
+	var zero T
+	// 生成代码的 ProtoReflect 允许 nil 接收者，借此拿到消息类型并新建实例
+	value := zero.ProtoReflect().Type().New().Interface().(T)
+	if err := proto.Unmarshal(bytes, value); err != nil {
+		return zero, err
+	}
+	return value, nil
+}
+
+// RawCodec 原始字节透传，不做任何编解码
+type RawCodec struct{}
+
+// Raw 返回原始字节透传编解码器
+func Raw() Codec[[]byte] {
+	return RawCodec{}
+}
+
+func (RawCodec) Marshal(value []byte) ([]byte, error) {
+	return value, nil
+}
+
+func (RawCodec) Unmarshal(bytes []byte) ([]byte, error) {
+	return bytes, nil
+}
@@ -3,6 +3,8 @@ package codec
 import (
 	"encoding/base64"
 	"testing"
+
+	"github.com/lance4117/gofuse/server/test/pb"
 )
 
 type User struct {
@@ -95,3 +97,44 @@ func TestBase64(t *testing.T) {
 
 	t.Log("out:", s, u, u2, raw)
 }
+
+func TestCodec(t *testing.T) {
+	user1 := User{111, "name111"}
+
+	for _, c := range []Codec[User]{Msgpack[User](), JSON[User]()} {
+		bytes, err := c.Marshal(user1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		user, err := c.Unmarshal(bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user != user1 {
+			t.Fatalf("%T mismatch, got %+v", c, user)
+		}
+	}
+
+	raw, err := Raw().Marshal([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(raw) != "hello" {
+		t.Fatalf("Raw mismatch, got %s", raw)
+	}
+}
+
+func TestProtoCodec(t *testing.T) {
+	c := Proto[*pb.HelloRequest]()
+	bytes, err := c.Marshal(&pb.HelloRequest{Name: "Tom"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := c.Unmarshal(bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.GetName() != "Tom" {
+		t.Fatalf("Proto mismatch, got %v", req)
+	}
+}
@@ -27,6 +27,21 @@ var (
 	ErrKeyNotFound        = errors.New(" key not found ")
 )
 
+// cache
+var (
+	ErrSnapshotCorrupted = errors.New(" cache snapshot corrupted ")
+	ErrSnapshotVersion   = errors.New(" unsupported cache snapshot version ")
+	ErrKeyTooLong        = errors.New(" cache key too long ")
+	ErrCacheClosed       = errors.New(" cache closed ")
+	ErrEntryTooLarge     = errors.New(" cache entry larger than max bytes ")
+)
+
+// pool
+var (
+	ErrPoolClosed = errors.New(" pool closed ")
+	ErrTaskPanic  = errors.New(" pool task panic ")
+)
+
 // chain
 var (
 	ErrNoBalance    = errors.New(" no balance ")
@@ -36,7 +51,13 @@ var (
 
 // eventbus crypt conv
 var (
-	ErrSubsPanic       = errors.New(" eventbus subscriber panic ")
-	ErrAESKeyLength    = errors.New(" key length must be 16,24,32")
-	ErrBigEndianLength = errors.New(" bytes length must be 8 ")
+	ErrSubsPanic          = errors.New(" eventbus subscriber panic ")
+	ErrQueueFull          = errors.New(" eventbus subscriber queue full ")
+	ErrConsumerExists     = errors.New(" eventbus durable consumer already exists ")
+	ErrSubscriberNotFound = errors.New(" eventbus subscriber not found ")
+	ErrBusClosed          = errors.New(" eventbus closed ")
+	ErrTopicNameTaken     = errors.New(" eventbus topic name used by another topic ")
+	ErrConsumerRequired   = errors.New(" eventbus redis transport group requires consumer name ")
+	ErrAESKeyLength       = errors.New(" key length must be 16,24,32")
+	ErrBigEndianLength    = errors.New(" bytes length must be 8 ")
 )
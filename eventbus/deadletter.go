@@ -0,0 +1,132 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lance4117/gofuse/errs"
+	"github.com/lance4117/gofuse/logger"
+)
+
+// DeadLetterTopic 死信主题，订阅者重试后仍失败的事件以 DeadLetter 为数据发布到该主题；
+// 该主题自身的订阅者失败时不再产生死信
+var DeadLetterTopic = TopicOf("eventbus.deadletter")
+
+// deadLetterLimit 每个事件总线保留的死信数量，超出时丢弃最早的死信
+const deadLetterLimit = 1000
+
+// DeadLetter 死信，记录处理失败的事件及失败原因
+type DeadLetter struct {
+	ID         uint64    // 死信编号
+	Topic      Topic     // 事件主题
+	Data       any       // 事件数据
+	Subscriber int       // 失败的订阅者ID
+	Attempts   int       // 执行次数
+	Err        error     // 最后一次的错误
+	Time       time.Time // 转入死信的时间
+}
+
+// deadLetters 按编号顺序保存的死信
+type deadLetters struct {
+	mu     sync.Mutex
+	items  []DeadLetter
+	nextID uint64
+}
+
+func (d *deadLetters) add(dl DeadLetter) DeadLetter {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.nextID++
+	dl.ID = d.nextID
+	if len(d.items) >= deadLetterLimit {
+		d.items = append(d.items[:0], d.items[1:]...)
+	}
+	d.items = append(d.items, dl)
+	return dl
+}
+
+func (d *deadLetters) take(id uint64) (DeadLetter, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, dl := range d.items {
+		if dl.ID == id {
+			d.items = append(d.items[:i], d.items[i+1:]...)
+			return dl, true
+		}
+	}
+	return DeadLetter{}, false
+}
+
+// restore 将取出的死信放回原位置
+func (d *deadLetters) restore(dl DeadLetter) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	i := 0
+	for i < len(d.items) && d.items[i].ID < dl.ID {
+		i++
+	}
+	d.items = append(d.items, DeadLetter{})
+	copy(d.items[i+1:], d.items[i:])
+	d.items[i] = dl
+}
+
+func (d *deadLetters) list() []DeadLetter {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make([]DeadLetter, len(d.items))
+	copy(out, d.items)
+	return out
+}
+
+// deadLetter 记录死信并发布到 DeadLetterTopic
+func (eb *EventBus) deadLetter(s *subscriber, e *Event, attempts int, err error) {
+	logger.Errorf("eventbus: topic=%s subscriber_id=%d failed after %d attempts: %v", e.Topic, s.id, attempts, err)
+	if e.Topic == DeadLetterTopic {
+		return
+	}
+	dl := eb.dead.add(DeadLetter{
+		Topic:      e.Topic,
+		Data:       e.Data,
+		Subscriber: s.id,
+		Attempts:   attempts,
+		Err:        err,
+		Time:       time.Now(),
+	})
+	eb.PublishAsync(DeadLetterTopic, dl)
+}
+
+// DeadLetters 返回当前保留的死信，按转入顺序排列
+func (eb *EventBus) DeadLetters() []DeadLetter {
+	return eb.dead.list()
+}
+
+// DiscardDeadLetter 丢弃指定死信，不存在时返回 false
+func (eb *EventBus) DiscardDeadLetter(id uint64) bool {
+	_, ok := eb.dead.take(id)
+	return ok
+}
+
+// ReplayDeadLetter 将死信重新交给原订阅者同步处理，仍然失败时以新的编号重新转入死信并返回错误；
+// 原订阅者已取消订阅时死信保持不变，返回 errs.ErrSubscriberNotFound
+func (eb *EventBus) ReplayDeadLetter(ctx context.Context, id uint64) error {
+	dl, ok := eb.dead.take(id)
+	if !ok {
+		return fmt.Errorf("%w: dead letter id=%d", errs.ErrKeyNotFound, id)
+	}
+	eb.mu.RLock()
+	sub, ok := eb.byID[dl.Subscriber]
+	eb.mu.RUnlock()
+	if !ok {
+		eb.dead.restore(dl)
+		return fmt.Errorf("%w: subscriber_id=%d", errs.ErrSubscriberNotFound, dl.Subscriber)
+	}
+
+	e := &Event{Topic: dl.Topic, Data: dl.Data, ctx: ctx}
+	attempts, err := sub.process(e)
+	if err != nil {
+		eb.deadLetter(sub, e, attempts, err)
+	}
+	return err
+}
@@ -0,0 +1,415 @@
+package eventbus
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/lance4117/gofuse/codec"
+	"github.com/lance4117/gofuse/errs"
+	"github.com/lance4117/gofuse/logger"
+	"github.com/lance4117/gofuse/store/kvs/pebblekv"
+)
+
+// 日志在 pebble 中的 key 布局：
+//
+//	eventbus/seq                              -> 最新分配的位点
+//	eventbus/log/<topic>\x00<offset BE>       -> time(8) | msgpack 编码的数据
+//	eventbus/ack/<topic>\x00<consumer>        -> 已确认的位点
+const (
+	durableSeqKey    = "eventbus/seq"
+	durableLogPrefix = "eventbus/log/"
+	durableAckPrefix = "eventbus/ack/"
+	durableReadBatch = 128
+)
+
+// DurableOptions 持久化事件总线配置
+type DurableOptions struct {
+	Sync          bool          // 追加事件与确认位点时是否同步刷盘，默认 false
+	RetryInterval time.Duration // 持久订阅者处理失败后重新投递的间隔，默认 1s
+}
+
+// DurableEvent 持久订阅者收到的事件
+type DurableEvent struct {
+	Offset uint64    // 事件位点，全局递增
+	Topic  Topic     // 事件主题
+	Time   time.Time // 发布时间
+	Data   []byte    // msgpack 编码的事件数据
+
+	ctx context.Context
+}
+
+// Decode 将事件数据解码到 v 指针
+func (e *DurableEvent) Decode(v any) error {
+	return codec.MPUnmarshal(e.Data, v)
+}
+
+// Context 返回订阅者的 context，取消订阅或关闭时结束
+func (e *DurableEvent) Context() context.Context {
+	return e.ctx
+}
+
+// DurableStore 持久化事件总线使用的存储，*pebblekv.PebbleKV 已实现，
+// 可包装后加入监控或命名空间隔离
+type DurableStore interface {
+	Get(key string) ([]byte, error)
+	Put(key string, val []byte) error
+	PutSync(key string, val []byte) error
+	NewBatch() *pebble.Batch
+	NewIterator(opt pebblekv.IterOption) (*pebble.Iterator, error)
+}
+
+// Durable 持久化事件总线，事件先追加到 pebble 日志再通过 EventBus 分发；
+// 持久订阅者按名称记录已确认的位点，处理成功即确认，失败则重新投递，重启后从上次确认的位点继续消费
+type Durable struct {
+	bus  *EventBus
+	kv   DurableStore
+	opts DurableOptions
+
+	mu        sync.Mutex
+	seq       uint64
+	consumers map[string]*durableConsumer
+}
+
+// NewDurable 创建持久化事件总线，kv 的生命周期由调用方管理
+func NewDurable(bus *EventBus, kv DurableStore, opts DurableOptions) (*Durable, error) {
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = time.Second
+	}
+	d := &Durable{
+		bus:       bus,
+		kv:        kv,
+		opts:      opts,
+		consumers: make(map[string]*durableConsumer),
+	}
+	seq, err := kv.Get(durableSeqKey)
+	switch {
+	case err == nil:
+		d.seq = binary.BigEndian.Uint64(seq)
+	case !errors.Is(err, errs.ErrKeyNotFound):
+		return nil, err
+	}
+	return d, nil
+}
+
+// Bus 返回底层的事件总线
+func (d *Durable) Bus() *EventBus {
+	return d.bus
+}
+
+// Publish 将事件追加到日志后分发，返回事件的位点；data 使用 msgpack 编码持久化
+func (d *Durable) Publish(ctx context.Context, topic Topic, data any) (uint64, error) {
+	payload, err := codec.MPMarshal(data)
+	if err != nil {
+		return 0, err
+	}
+	record := make([]byte, 8+len(payload))
+	binary.BigEndian.PutUint64(record, uint64(time.Now().UnixNano()))
+	copy(record[8:], payload)
+
+	d.mu.Lock()
+	offset := d.seq + 1
+	batch := d.kv.NewBatch()
+	_ = batch.Set(durableLogKey(topic, offset), record, nil)
+	_ = batch.Set([]byte(durableSeqKey), binary.BigEndian.AppendUint64(nil, offset), nil)
+	err = batch.Commit(d.writeOpts())
+	_ = batch.Close()
+	if err != nil {
+		d.mu.Unlock()
+		return 0, err
+	}
+	d.seq = offset
+	var notify []*durableConsumer
+	for _, c := range d.consumers {
+		if c.topic == topic {
+			notify = append(notify, c)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, c := range notify {
+		c.wake()
+	}
+	return offset, d.bus.publish(ctx, topic, data)
+}
+
+// Subscribe 以 name 注册持久订阅者，从上次确认的位点之后开始消费；
+// fn 返回 nil 即确认，返回错误或 panic 时按 RetryInterval 重新投递同一事件，
+// 同一主题下的 name 不能重复订阅，返回取消订阅函数
+func (d *Durable) Subscribe(name string, topic Topic, fn func(e *DurableEvent) error) (func(), error) {
+	id := durableTopicKey(topic) + "\x00" + name
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.consumers[id]; ok {
+		return nil, fmt.Errorf("%w: topic=%s name=%s", errs.ErrConsumerExists, topic, name)
+	}
+	acked, err := d.register(name, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &durableConsumer{
+		d:      d,
+		name:   name,
+		topic:  topic,
+		fn:     fn,
+		acked:  acked,
+		notify: make(chan struct{}, 1),
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	d.consumers[id] = c
+	go c.run()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.stop()
+			d.mu.Lock()
+			delete(d.consumers, id)
+			d.mu.Unlock()
+		})
+	}, nil
+}
+
+// SubscribeDurable 以 name 注册带类型的持久订阅者，语义同 Durable.Subscribe
+func SubscribeDurable[T any](d *Durable, name string, topic *TypedTopic[T], fn func(ctx context.Context, data T) error) (func(), error) {
+	return d.Subscribe(name, topic.Topic(), func(e *DurableEvent) error {
+		var data T
+		if err := e.Decode(&data); err != nil {
+			return err
+		}
+		return fn(e.Context(), data)
+	})
+}
+
+// Acked 返回持久订阅者已确认的位点，未消费过时为 0
+func (d *Durable) Acked(name string, topic Topic) (uint64, error) {
+	b, err := d.kv.Get(durableAckKey(topic, name))
+	if errors.Is(err, errs.ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// register 返回已确认的位点，首次订阅时写入初始位点 0，
+// 使 Trim 在订阅者确认第一个事件之前不会删除日志
+func (d *Durable) register(name string, topic Topic) (uint64, error) {
+	b, err := d.kv.Get(durableAckKey(topic, name))
+	if err == nil {
+		return binary.BigEndian.Uint64(b), nil
+	}
+	if !errors.Is(err, errs.ErrKeyNotFound) {
+		return 0, err
+	}
+	put := d.kv.Put
+	if d.opts.Sync {
+		put = d.kv.PutSync
+	}
+	return 0, put(durableAckKey(topic, name), binary.BigEndian.AppendUint64(nil, 0))
+}
+
+// Trim 删除主题下所有持久订阅者都已确认的事件，返回删除的数量；
+// 没有持久订阅者记录的主题不做处理，订阅过但尚未确认任何事件的订阅者会阻止删除
+func (d *Durable) Trim(topic Topic) (int, error) {
+	it, err := d.kv.NewIterator(pebblekv.IterOption{Prefix: []byte(durableAckPrefix + durableTopicKey(topic) + "\x00")})
+	if err != nil {
+		return 0, err
+	}
+	var minAcked uint64
+	found := false
+	for ; it.Valid(); it.Next() {
+		acked := binary.BigEndian.Uint64(it.Value())
+		if !found || acked < minAcked {
+			minAcked = acked
+		}
+		found = true
+	}
+	if err = it.Close(); err != nil || !found || minAcked == 0 {
+		return 0, err
+	}
+
+	prefix := []byte(durableLogPrefix + durableTopicKey(topic) + "\x00")
+	it, err = d.kv.NewIterator(pebblekv.IterOption{Prefix: prefix})
+	if err != nil {
+		return 0, err
+	}
+	defer it.Close()
+	batch := d.kv.NewBatch()
+	defer batch.Close()
+	var n int
+	end := durableLogKey(topic, minAcked)
+	for ; it.Valid() && bytes.Compare(it.Key(), end) <= 0; it.Next() {
+		_ = batch.Delete(bytes.Clone(it.Key()), nil)
+		n++
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	return n, batch.Commit(d.writeOpts())
+}
+
+// Close 停止所有持久订阅者，不关闭 kv
+func (d *Durable) Close() error {
+	d.mu.Lock()
+	consumers := d.consumers
+	d.consumers = make(map[string]*durableConsumer)
+	d.mu.Unlock()
+	for _, c := range consumers {
+		c.stop()
+	}
+	return nil
+}
+
+func (d *Durable) writeOpts() *pebble.WriteOptions {
+	if d.opts.Sync {
+		return pebble.Sync
+	}
+	return pebble.NoSync
+}
+
+// durableConsumer 持久订阅者，单个 goroutine 按位点顺序消费
+type durableConsumer struct {
+	d      *Durable
+	name   string
+	topic  Topic
+	fn     func(e *DurableEvent) error
+	acked  uint64
+	notify chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (c *durableConsumer) wake() {
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (c *durableConsumer) stop() {
+	c.cancel()
+	<-c.done
+}
+
+func (c *durableConsumer) run() {
+	defer close(c.done)
+	for {
+		events, err := c.read()
+		if err != nil {
+			logger.Errorf("eventbus: durable consumer topic=%s name=%s read log: %v", c.topic, c.name, err)
+		}
+		for _, e := range events {
+			if !c.deliver(e) {
+				return
+			}
+		}
+		if err == nil && len(events) == durableReadBatch {
+			continue
+		}
+
+		var retry <-chan time.Time
+		if err != nil {
+			retry = time.After(c.d.opts.RetryInterval)
+		}
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-c.notify:
+		case <-retry:
+		}
+	}
+}
+
+// read 读取已确认位点之后的一批事件
+func (c *durableConsumer) read() ([]*DurableEvent, error) {
+	prefix := []byte(durableLogPrefix + durableTopicKey(c.topic) + "\x00")
+	it, err := c.d.kv.NewIterator(pebblekv.IterOption{Prefix: prefix})
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var events []*DurableEvent
+	for it.SeekGE(durableLogKey(c.topic, c.acked+1)); it.Valid() && len(events) < durableReadBatch; it.Next() {
+		key, value := it.Key(), it.Value()
+		if len(key) < len(prefix)+8 || len(value) < 8 {
+			continue
+		}
+		events = append(events, &DurableEvent{
+			Offset: binary.BigEndian.Uint64(key[len(prefix):]),
+			Topic:  c.topic,
+			Time:   time.Unix(0, int64(binary.BigEndian.Uint64(value))),
+			Data:   bytes.Clone(value[8:]),
+			ctx:    c.ctx,
+		})
+	}
+	return events, it.Error()
+}
+
+// deliver 投递事件直到处理成功并确认，订阅者停止时返回 false
+func (c *durableConsumer) deliver(e *DurableEvent) bool {
+	for {
+		err := c.invoke(e)
+		if err == nil {
+			break
+		}
+		logger.Warnf("eventbus: durable consumer topic=%s name=%s offset=%d: %v", c.topic, c.name, e.Offset, err)
+		select {
+		case <-c.ctx.Done():
+			return false
+		case <-time.After(c.d.opts.RetryInterval):
+		}
+	}
+
+	ack := binary.BigEndian.AppendUint64(nil, e.Offset)
+	put := c.d.kv.Put
+	if c.d.opts.Sync {
+		put = c.d.kv.PutSync
+	}
+	if err := put(durableAckKey(c.topic, c.name), ack); err != nil {
+		// 确认失败时事件会在重启后重新投递
+		logger.Errorf("eventbus: durable consumer topic=%s name=%s ack offset=%d: %v", c.topic, c.name, e.Offset, err)
+	}
+	c.acked = e.Offset
+	return c.ctx.Err() == nil
+}
+
+func (c *durableConsumer) invoke(e *DurableEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: panic=%v stack=%s", errs.ErrSubsPanic, r, debug.Stack())
+		}
+	}()
+	return c.fn(e)
+}
+
+// durableTopicKey 主题在日志中的标识，具名主题使用名称，保证重启后一致
+func durableTopicKey(topic Topic) string {
+	if name := topic.Name(); name != "" {
+		return name
+	}
+	return "#" + strconv.Itoa(int(topic))
+}
+
+func durableLogKey(topic Topic, offset uint64) []byte {
+	key := []byte(durableLogPrefix + durableTopicKey(topic) + "\x00")
+	return binary.BigEndian.AppendUint64(key, offset)
+}
+
+func durableAckKey(topic Topic, name string) string {
+	return durableAckPrefix + durableTopicKey(topic) + "\x00" + name
+}
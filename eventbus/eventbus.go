@@ -1,55 +1,176 @@
+// Package eventbus 进程内的发布/订阅事件总线。
+//
+// EventBus 支持泛型主题（TypedTopic）、层级主题通配订阅（"*"/"#"）、订阅者有界队列与溢出策略、
+// 顺序及按 key 分区消费、订阅中间件、失败重试与死信重放、异步发布、优雅关闭及 panic 恢复；
+// Durable 基于 Pebble 持久化事件并按名称记录消费位点；Bridge 通过 Transport（如 Redis Streams）在实例之间同步事件。
 package eventbus
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"runtime/debug"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/lance4117/gofuse/errs"
 	"github.com/lance4117/gofuse/logger"
 	"github.com/lance4117/gofuse/once"
+	"github.com/lance4117/gofuse/pool"
 )
 
-// Topic 事件主题类型，建议使用 iota 定义常量
+// Topic 事件主题类型，建议使用 iota 定义常量，或通过 TopicOf 按名称获取
 type Topic int
 
 // Event 事件结构体，包含主题和数据
 type Event struct {
 	Topic Topic // 事件主题
 	Data  any   // 事件携带的数据
+
+	ctx context.Context
+}
+
+// Context 返回发布事件时传入的 context，未传入时为 context.Background()
+func (e *Event) Context() context.Context {
+	if e.ctx == nil {
+		return context.Background()
+	}
+	return e.ctx
 }
 
 // subscriber 内部订阅者结构，包装订阅函数和唯一ID
 type subscriber struct {
-	id int                // 唯一标识符，用于取消订阅
-	fn func(event *Event) // 订阅者回调函数
+	id       int                      // 唯一标识符，用于取消订阅
+	bus      *EventBus                // 所属事件总线，用于投递死信
+	fn       func(event *Event) error // 订阅者回调函数
+	mws      []Middleware             // 订阅者中间件
+	pool     *pool.Pool               // 可选，执行订阅函数的池
+	retries  int                      // 失败后的重试次数
+	backoff  Backoff                  // 重试等待策略
+	queue    *subQueue                // 可选，有界队列，为空时发布方等待处理完成
+	done     chan struct{}            // 取消订阅时关闭
+	stopOnce sync.Once
+}
+
+func (eb *EventBus) newSubscriber(id int, fn func(event *Event) error, opts []SubscribeOption) *subscriber {
+	o := newSubscribeOptions(opts)
+	sub := &subscriber{
+		id:      id,
+		bus:     eb,
+		fn:      fn,
+		mws:     o.middlewares,
+		pool:    o.pool,
+		retries: o.retries,
+		backoff: o.backoff,
+		done:    make(chan struct{}),
+	}
+	if o.bufferSize > 0 {
+		sub.queue = newSubQueue(sub, o)
+	}
+	return sub
+}
+
+// invoke 经中间件执行订阅函数，订阅者 panic 不影响其他订阅者
+func (s *subscriber) invoke(e *Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Errorf("%s: topic=%s subscriber_id=%d panic=%v stack=%s",
+				errs.ErrSubsPanic.Error(), e.Topic, s.id, r, debug.Stack())
+			err = fmt.Errorf("%w: %v", errs.ErrSubsPanic, r)
+		}
+	}()
+	h := chain(s.fn, s.mws)
+	if mws := s.bus.mws.Load(); mws != nil {
+		h = chain(h, *mws)
+	}
+	return h(e)
+}
+
+// process 执行订阅函数并按重试策略重试，返回最后一次的错误与执行次数
+func (s *subscriber) process(e *Event) (int, error) {
+	attempts := 1
+	err := s.invoke(e)
+	for ; err != nil && attempts <= s.retries; attempts++ {
+		if s.backoff != nil {
+			select {
+			case <-time.After(s.backoff(attempts)):
+			case <-s.done:
+				return attempts, err
+			}
+		}
+		err = s.invoke(e)
+	}
+	return attempts, err
+}
+
+// handle 处理事件，重试后仍失败时转入死信
+func (s *subscriber) handle(e *Event) {
+	if attempts, err := s.process(e); err != nil {
+		s.bus.deadLetter(s, e, attempts, err)
+	}
+}
+
+func (s *subscriber) stop() {
+	s.stopOnce.Do(func() {
+		close(s.done)
+	})
 }
 
 // EventBus 事件总线，管理所有主题的订阅和发布
 type EventBus struct {
-	subs   map[Topic][]subscriber // 主题到订阅者列表的映射
-	mu     sync.RWMutex           // 读写锁，保护并发访问
-	nextID int                    // 订阅者ID生成器
+	subs     map[Topic][]*subscriber // 主题到订阅者列表的映射
+	patterns patternTrie             // 通配订阅
+	byID     map[int]*subscriber     // 订阅者ID到订阅者的映射，用于重放死信
+	mu       sync.RWMutex            // 读写锁，保护并发访问
+	nextID   int                     // 订阅者ID生成器
+	dead     deadLetters             // 死信
+	mws      atomic.Pointer[[]Middleware]
+	closed   bool           // 是否已关闭，由 mu 保护
+	inflight sync.WaitGroup // 进行中的发布
 }
 
 // GetEventBus 获取全局单例事件总线实例
-var GetEventBus = once.Do(func() *EventBus {
+var GetEventBus = once.Do(NewEventBus)
+
+// NewEventBus 创建独立的事件总线实例，便于测试或按模块隔离
+func NewEventBus() *EventBus {
 	return &EventBus{
-		subs: make(map[Topic][]subscriber),
+		subs: make(map[Topic][]*subscriber),
+		byID: make(map[int]*subscriber),
 	}
-})
+}
+
+// Subscribe 订阅主题，返回取消订阅函数；可通过 WithBuffer 等选项使用有界队列异步消费
+func (eb *EventBus) Subscribe(topic Topic, fn func(event *Event), opts ...SubscribeOption) func() {
+	return eb.SubscribeE(topic, func(event *Event) error {
+		fn(event)
+		return nil
+	}, opts...)
+}
 
-// Subscribe 订阅主题，返回取消订阅函数
-func (eb *EventBus) Subscribe(topic Topic, fn func(event *Event)) func() {
+// SubscribeE 同 Subscribe，订阅函数返回错误时按 WithRetry 重试，仍失败的事件转入死信；
+// 事件总线已关闭时不订阅，返回空的取消订阅函数
+func (eb *EventBus) SubscribeE(topic Topic, fn func(event *Event) error, opts ...SubscribeOption) func() {
 	eb.mu.Lock()
+	if eb.closed {
+		eb.mu.Unlock()
+		logger.Warnf("eventbus: subscribe topic=%s: %v", topic, errs.ErrBusClosed)
+		return func() {}
+	}
 	id := eb.nextID
 	eb.nextID++
-	eb.subs[topic] = append(eb.subs[topic], subscriber{id: id, fn: fn})
+	sub := eb.newSubscriber(id, fn, opts)
+	eb.subs[topic] = append(eb.subs[topic], sub)
+	eb.byID[id] = sub
 	eb.mu.Unlock()
 
 	// 返回取消订阅函数
 	return func() {
 		eb.mu.Lock()
 		defer eb.mu.Unlock()
+		sub.stop()
+		delete(eb.byID, id)
 		subs := eb.subs[topic]
 		for i, s := range subs {
 			if s.id == id {
@@ -60,59 +181,230 @@ func (eb *EventBus) Subscribe(topic Topic, fn func(event *Event)) func() {
 	}
 }
 
-// Publish 发布事件到指定主题
+// SubscribePattern 按名称通配订阅，名称按 "." 分段，"*" 匹配一段，"#" 匹配零段或多段，
+// 如 "order.*" 匹配 "order.created"，"order.#" 匹配 "order" 与 "order.item.added"；
+// 只有具名主题（TopicOf、RegisterTopic、NewTopic）会参与匹配，返回取消订阅函数
+func (eb *EventBus) SubscribePattern(pattern string, fn func(event *Event), opts ...SubscribeOption) func() {
+	return eb.SubscribePatternE(pattern, func(event *Event) error {
+		fn(event)
+		return nil
+	}, opts...)
+}
+
+// SubscribePatternE 同 SubscribePattern，订阅函数返回错误时按 WithRetry 重试，仍失败的事件转入死信；
+// 事件总线已关闭时不订阅，返回空的取消订阅函数
+func (eb *EventBus) SubscribePatternE(pattern string, fn func(event *Event) error, opts ...SubscribeOption) func() {
+	eb.mu.Lock()
+	if eb.closed {
+		eb.mu.Unlock()
+		logger.Warnf("eventbus: subscribe pattern=%s: %v", pattern, errs.ErrBusClosed)
+		return func() {}
+	}
+	id := eb.nextID
+	eb.nextID++
+	sub := eb.newSubscriber(id, fn, opts)
+	eb.patterns.add(pattern, sub)
+	eb.byID[id] = sub
+	eb.mu.Unlock()
+
+	return func() {
+		eb.mu.Lock()
+		sub.stop()
+		delete(eb.byID, id)
+		eb.patterns.remove(pattern, id)
+		eb.mu.Unlock()
+	}
+}
+
+// Publish 发布事件到指定主题，等待未使用队列的订阅者处理完成，
+// 使用队列的订阅者只等待入队
 func (eb *EventBus) Publish(topic Topic, data any) {
+	if err := eb.publish(context.Background(), topic, data); err != nil {
+		logger.Warnf("eventbus: publish topic=%s: %v", topic, err)
+	}
+}
+
+// TryPublish 同 Publish，返回队列已满（OverflowError）等入队失败的错误
+func (eb *EventBus) TryPublish(topic Topic, data any) error {
+	return eb.publish(context.Background(), topic, data)
+}
+
+// PublishCtx 同 Publish，ctx 通过 Event.Context 传递给订阅者，并用于取消阻塞的入队；
+// 返回入队失败、ctx 结束或事件总线已关闭（errs.ErrBusClosed）的错误
+func (eb *EventBus) PublishCtx(ctx context.Context, topic Topic, data any) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return eb.publish(ctx, topic, data)
+}
+
+// begin 登记一次发布并返回主题的订阅者，包含匹配的通配订阅者；
+// 事件总线已关闭时返回 errs.ErrBusClosed，否则调用方投递完成后需调用 eb.inflight.Done()
+func (eb *EventBus) begin(topic Topic) ([]*subscriber, error) {
+	name := topic.Name()
 	eb.mu.RLock()
-	subs := make([]subscriber, len(eb.subs[topic]))
-	copy(subs, eb.subs[topic]) // 复制一份避免长时间持锁
-	eb.mu.RUnlock()
+	defer eb.mu.RUnlock()
+	if eb.closed {
+		return nil, errs.ErrBusClosed
+	}
+	eb.inflight.Add(1)
+	matched := eb.patterns.match(name)
+	subs := make([]*subscriber, 0, len(eb.subs[topic])+len(matched))
+	subs = append(subs, eb.subs[topic]...) // 复制一份避免长时间持锁
+	return append(subs, matched...), nil
+}
+
+func (eb *EventBus) publish(ctx context.Context, topic Topic, data any) error {
+	subs, err := eb.begin(topic)
+	if err != nil {
+		return err
+	}
+	defer eb.inflight.Done()
 
 	if len(subs) == 0 {
-		return
+		return nil
 	}
 
-	event := &Event{Topic: topic, Data: data}
+	return dispatch(&Event{Topic: topic, Data: data, ctx: ctx}, subs)
+}
 
+// dispatch 将事件投递给订阅者
+func dispatch(event *Event, subs []*subscriber) error {
+	var errList []error
 	var wg sync.WaitGroup
-	wg.Add(len(subs))
-	for _, s := range subs {
-		go func(sub subscriber) {
-			defer func() {
-				if r := recover(); r != nil {
-					// 订阅者 panic 不影响其他订阅者
-					logger.Errorf("%s: topic=%d subscriber_id=%d panic=%v stack=%s",
-						errs.ErrSubsPanic.Error(), topic, sub.id, r, debug.Stack())
-				}
-				wg.Done()
-			}()
-			sub.fn(event)
-		}(s)
+	for _, sub := range subs {
+		if sub.queue != nil {
+			if err := sub.queue.push(event); err != nil {
+				errList = append(errList, err)
+			}
+			continue
+		}
+		wg.Add(1)
+		run := func() {
+			defer wg.Done()
+			sub.handle(event)
+		}
+		if sub.pool == nil || sub.pool.Go(run) != nil {
+			go run()
+		}
 	}
 	wg.Wait()
+	return errors.Join(errList...)
 }
 
-// PublishAsync 异步发布事件，不等待处理完成
+// PublishAsync 异步发布事件，不等待处理完成；
+// 使用队列的订阅者在调用方直接入队，保持发布顺序，OverflowBlock 策略下队列已满时阻塞直到有空位；
+// 未使用队列的订阅者在一个 goroutine 中投递
 func (eb *EventBus) PublishAsync(topic Topic, data any) {
-	go eb.Publish(topic, data)
+	eb.PublishAsyncCtx(context.Background(), topic, data)
+}
+
+// PublishAsyncCtx 同 PublishAsync，ctx 通过 Event.Context 传递给订阅者，并用于取消阻塞的入队
+func (eb *EventBus) PublishAsyncCtx(ctx context.Context, topic Topic, data any) {
+	subs, err := eb.begin(topic)
+	if err != nil {
+		logger.Warnf("eventbus: publish topic=%s: %v", topic, err)
+		return
+	}
+	if len(subs) == 0 {
+		eb.inflight.Done()
+		return
+	}
+
+	event := &Event{Topic: topic, Data: data, ctx: ctx}
+	rest := subs[:0]
+	for _, sub := range subs {
+		if sub.queue != nil {
+			if err := sub.queue.push(event); err != nil {
+				logger.Warnf("eventbus: publish topic=%s: %v", topic, err)
+			}
+			continue
+		}
+		rest = append(rest, sub)
+	}
+	if len(rest) == 0 {
+		eb.inflight.Done()
+		return
+	}
+	go func() {
+		defer eb.inflight.Done()
+		if err := dispatch(event, rest); err != nil {
+			logger.Warnf("eventbus: publish topic=%s: %v", topic, err)
+		}
+	}()
 }
 
-// Unsubscribe 取消订阅整个主题
+// Unsubscribe 取消订阅整个主题，不影响通配订阅
 func (eb *EventBus) Unsubscribe(topic Topic) {
 	eb.mu.Lock()
+	for _, sub := range eb.subs[topic] {
+		sub.stop()
+		delete(eb.byID, sub.id)
+	}
 	delete(eb.subs, topic)
 	eb.mu.Unlock()
 }
 
-// HasSubscribers 检查主题是否有订阅者
+// HasSubscribers 检查主题是否有订阅者，包含匹配的通配订阅者
 func (eb *EventBus) HasSubscribers(topic Topic) bool {
-	eb.mu.RLock()
-	defer eb.mu.RUnlock()
-	return len(eb.subs[topic]) > 0
+	return eb.SubscriberCount(topic) > 0
 }
 
-// SubscriberCount 返回主题的订阅者数量
+// SubscriberCount 返回主题的订阅者数量，包含匹配的通配订阅者
 func (eb *EventBus) SubscriberCount(topic Topic) int {
+	name := topic.Name()
 	eb.mu.RLock()
 	defer eb.mu.RUnlock()
-	return len(eb.subs[topic])
+	return len(eb.subs[topic]) + len(eb.patterns.match(name))
+}
+
+// Use 注册作用于所有订阅者的中间件，先注册的在外层，且位于订阅者自身中间件（WithMiddleware）之外
+func (eb *EventBus) Use(mws ...Middleware) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	var all []Middleware
+	if old := eb.mws.Load(); old != nil {
+		all = append(all, *old...)
+	}
+	all = append(all, mws...)
+	eb.mws.Store(&all)
+}
+
+// Close 停止接收新事件，等待进行中的发布及队列中的事件处理完成后停止所有订阅者；
+// ctx 结束时不再等待，丢弃未处理的事件并返回 ctx 的错误。
+// 关闭后发布返回 errs.ErrBusClosed，订阅者在处理中发布的事件同样会被拒绝
+func (eb *EventBus) Close(ctx context.Context) error {
+	eb.mu.Lock()
+	if eb.closed {
+		eb.mu.Unlock()
+		return nil
+	}
+	eb.closed = true
+	subs := make([]*subscriber, 0, len(eb.byID))
+	for _, sub := range eb.byID {
+		subs = append(subs, sub)
+	}
+	eb.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		eb.inflight.Wait()
+		for _, sub := range subs {
+			if sub.queue != nil {
+				sub.queue.drain()
+			}
+		}
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	for _, sub := range subs {
+		sub.stop()
+	}
+	return err
 }
@@ -1,9 +1,23 @@
 package eventbus
 
 import (
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/lance4117/gofuse/codec"
+	"github.com/lance4117/gofuse/errs"
+	"github.com/lance4117/gofuse/gen"
+	"github.com/lance4117/gofuse/pool"
+	"github.com/lance4117/gofuse/store/kvs"
+	"github.com/lance4117/gofuse/store/kvs/pebblekv"
+	"github.com/redis/go-redis/v9"
 )
 
 const (
@@ -113,3 +127,872 @@ func TestPanicRecovery(t *testing.T) {
 		t.Error("second subscriber should be called even if first panics")
 	}
 }
+
+type orderCreated struct {
+	ID     int
+	Amount float64
+}
+
+type ctxKey struct{}
+
+func TestTypedTopic(t *testing.T) {
+	topic := NewTopic[orderCreated]("test.order.created")
+	defer GetEventBus().Unsubscribe(topic.Topic())
+
+	if NewTopic[orderCreated]("test.order.created").Topic() != topic.Topic() {
+		t.Fatal("same name should map to the same topic")
+	}
+	if topic.Topic().String() != "test.order.created" {
+		t.Errorf("unexpected topic name %s", topic.Topic())
+	}
+
+	var got orderCreated
+	var traceID any
+	topic.Subscribe(func(ctx context.Context, e orderCreated) {
+		got = e
+		traceID = ctx.Value(ctxKey{})
+	})
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "trace-1")
+	topic.Publish(ctx, orderCreated{ID: 1, Amount: 9.9})
+	if got.ID != 1 || got.Amount != 9.9 {
+		t.Errorf("unexpected event %+v", got)
+	}
+	if traceID != "trace-1" {
+		t.Errorf("context not propagated, got %v", traceID)
+	}
+
+	// 通过非泛型接口发布错误类型的数据时，订阅者不会被调用
+	got = orderCreated{}
+	GetEventBus().Publish(topic.Topic(), "wrong type")
+	if got.ID != 0 {
+		t.Error("subscriber should not be called with mismatched data")
+	}
+}
+
+func TestTypedPublishAsyncCtx(t *testing.T) {
+	bus := NewEventBus()
+	defer bus.Close(context.Background())
+	topic := NewTopicOn[orderCreated](bus, "test.order.async")
+
+	traceIDs := make(chan any, 1)
+	topic.Subscribe(func(ctx context.Context, e orderCreated) {
+		traceIDs <- ctx.Value(ctxKey{})
+	})
+
+	topic.PublishAsync(context.WithValue(context.Background(), ctxKey{}, "trace-async"), orderCreated{ID: 2})
+	select {
+	case traceID := <-traceIDs:
+		if traceID != "trace-async" {
+			t.Errorf("context not propagated, got %v", traceID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for async event")
+	}
+}
+
+func TestRegisterTopic(t *testing.T) {
+	const TopicNamed Topic = 102
+	if TopicTest2.String() != "2" {
+		t.Errorf("unexpected name %s", TopicTest2)
+	}
+	if err := RegisterTopic(TopicNamed, "test.named"); err != nil {
+		t.Fatal(err)
+	}
+	if TopicNamed.String() != "test.named" || TopicOf("test.named") != TopicNamed {
+		t.Errorf("register failed, got %s", TopicNamed)
+	}
+	if err := RegisterTopic(TopicNamed+1, "test.named"); !errors.Is(err, errs.ErrTopicNameTaken) {
+		t.Errorf("expected ErrTopicNameTaken, got %v", err)
+	}
+}
+
+func TestSubscribePattern(t *testing.T) {
+	bus := GetEventBus()
+	created := TopicOf("test.order.created")
+	paid := TopicOf("test.order.payment.paid")
+	root := TopicOf("test.order")
+	user := TopicOf("test.user.created")
+
+	var one, many, exact int32
+	cancelOne := bus.SubscribePattern("test.order.*", func(e *Event) { atomic.AddInt32(&one, 1) })
+	cancelMany := bus.SubscribePattern("test.order.#", func(e *Event) { atomic.AddInt32(&many, 1) })
+	cancelExact := bus.SubscribePattern("test.*.created", func(e *Event) { atomic.AddInt32(&exact, 1) })
+	defer cancelExact()
+
+	if bus.SubscriberCount(created) != 3 {
+		t.Errorf("expected 3 subscribers, got %d", bus.SubscriberCount(created))
+	}
+
+	for _, topic := range []Topic{created, paid, root, user} {
+		bus.Publish(topic, "data")
+	}
+	// 未命名的主题不参与通配匹配
+	bus.Publish(TopicTest2, "data")
+
+	if one != 1 || many != 3 || exact != 2 {
+		t.Errorf("unexpected counts one=%d many=%d exact=%d", one, many, exact)
+	}
+
+	cancelOne()
+	cancelMany()
+	if bus.SubscriberCount(created) != 1 || bus.HasSubscribers(paid) {
+		t.Error("pattern subscribers should be removed after cancel")
+	}
+}
+
+func TestBoundedQueue(t *testing.T) {
+	bus := GetEventBus()
+	const TopicQueue Topic = 103
+	defer bus.Unsubscribe(TopicQueue)
+
+	// 订阅函数阻塞期间，队列容量为 2
+	block := make(chan struct{})
+	started := make(chan struct{}, 1)
+	var mu sync.Mutex
+	var got []any
+	bus.Subscribe(TopicQueue, func(e *Event) {
+		select {
+		case started <- struct{}{}:
+			<-block
+		default:
+		}
+		mu.Lock()
+		got = append(got, e.Data)
+		mu.Unlock()
+	}, WithBuffer(2), WithOverflow(OverflowError))
+
+	// 第一个事件被 worker 取走并阻塞
+	if err := bus.TryPublish(TopicQueue, 0); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	for i := 1; i <= 2; i++ {
+		if err := bus.TryPublish(TopicQueue, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := bus.TryPublish(TopicQueue, 3); !errors.Is(err, errs.ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+	close(block)
+
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 3 {
+		t.Errorf("expected 3 events, got %v", got)
+	}
+}
+
+func TestPublishAsyncBlock(t *testing.T) {
+	bus := NewEventBus()
+	defer bus.Close(context.Background())
+	const TopicAsyncBlock Topic = 1
+
+	base := runtime.NumGoroutine()
+	block := make(chan struct{})
+	var handled int32
+	bus.Subscribe(TopicAsyncBlock, func(e *Event) {
+		<-block
+		atomic.AddInt32(&handled, 1)
+	}, WithBuffer(1))
+
+	// 队列已满时 PublishAsync 阻塞调用方，不为每次发布启动 goroutine
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		for i := 0; i < 1000; i++ {
+			bus.PublishAsync(TopicAsyncBlock, i)
+		}
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if n := runtime.NumGoroutine() - base; n > 5 {
+		t.Errorf("expected bounded goroutines, got %d extra", n)
+	}
+	close(block)
+	<-published
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&handled) != 1000 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&handled); n != 1000 {
+		t.Errorf("expected 1000 handled events, got %d", n)
+	}
+}
+
+func TestOverflowDrop(t *testing.T) {
+	bus := GetEventBus()
+	const TopicDrop Topic = 104
+	defer bus.Unsubscribe(TopicDrop)
+
+	for _, tc := range []struct {
+		policy OverflowPolicy
+		want   []any
+	}{
+		{OverflowDropOldest, []any{0, 3, 4}},
+		{OverflowDropNewest, []any{0, 1, 2}},
+	} {
+		block := make(chan struct{})
+		started := make(chan struct{}, 1)
+		var mu sync.Mutex
+		var got []any
+		cancel := bus.Subscribe(TopicDrop, func(e *Event) {
+			select {
+			case started <- struct{}{}:
+				<-block
+			default:
+			}
+			mu.Lock()
+			got = append(got, e.Data)
+			mu.Unlock()
+		}, WithBuffer(2), WithOverflow(tc.policy))
+
+		bus.Publish(TopicDrop, 0)
+		<-started
+		for i := 1; i <= 4; i++ {
+			bus.Publish(TopicDrop, i)
+		}
+		close(block)
+		time.Sleep(50 * time.Millisecond)
+
+		mu.Lock()
+		if len(got) != len(tc.want) {
+			t.Errorf("policy %d: expected %v, got %v", tc.policy, tc.want, got)
+		}
+		for i := range got {
+			if i < len(tc.want) && got[i] != tc.want[i] {
+				t.Errorf("policy %d: expected %v, got %v", tc.policy, tc.want, got)
+				break
+			}
+		}
+		mu.Unlock()
+		cancel()
+	}
+}
+
+func TestQueueWorkersWithPool(t *testing.T) {
+	bus := GetEventBus()
+	const TopicWorkers Topic = 105
+	defer bus.Unsubscribe(TopicWorkers)
+
+	p, err := pool.New(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Release()
+
+	var running, peak, done int32
+	bus.Subscribe(TopicWorkers, func(e *Event) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			old := atomic.LoadInt32(&peak)
+			if n <= old || atomic.CompareAndSwapInt32(&peak, old, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		atomic.AddInt32(&done, 1)
+	}, WithBuffer(16), WithWorkers(4), WithPool(p))
+
+	for i := 0; i < 8; i++ {
+		bus.PublishAsync(TopicWorkers, i)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	if atomic.LoadInt32(&done) != 8 {
+		t.Errorf("expected 8 events handled, got %d", done)
+	}
+	// 4 个 worker 共享容量为 2 的池
+	if p := atomic.LoadInt32(&peak); p > 2 || p < 1 {
+		t.Errorf("unexpected peak concurrency %d", p)
+	}
+}
+
+func TestOrdered(t *testing.T) {
+	bus := GetEventBus()
+	const TopicOrdered Topic = 106
+	defer bus.Unsubscribe(TopicOrdered)
+
+	var mu sync.Mutex
+	var got []int
+	bus.Subscribe(TopicOrdered, func(e *Event) {
+		n := e.Data.(int)
+		// 前面的事件处理得更慢，非顺序模式下会乱序
+		time.Sleep(time.Duration(10-n) * time.Millisecond)
+		mu.Lock()
+		got = append(got, n)
+		mu.Unlock()
+	}, WithOrdered())
+
+	for i := 0; i < 10; i++ {
+		bus.Publish(TopicOrdered, i)
+	}
+	time.Sleep(150 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 10 {
+		t.Fatalf("expected 10 events, got %v", got)
+	}
+	for i, n := range got {
+		if n != i {
+			t.Fatalf("events out of order: %v", got)
+		}
+	}
+}
+
+func TestPublishAsyncOrdered(t *testing.T) {
+	bus := NewEventBus()
+	const TopicAsyncOrdered, TopicAsyncPartition Topic = 1, 2
+
+	const n = 2000
+	var ordered []int
+	bus.Subscribe(TopicAsyncOrdered, func(e *Event) {
+		ordered = append(ordered, e.Data.(int))
+	}, WithOrdered(), WithBuffer(16))
+
+	var mu sync.Mutex
+	byKey := make(map[string][]int)
+	bus.Subscribe(TopicAsyncPartition, func(e *Event) {
+		p := e.Data.(partitioned)
+		mu.Lock()
+		byKey[p.Key] = append(byKey[p.Key], p.Seq)
+		mu.Unlock()
+	}, WithPartitionKey(func(e *Event) string { return e.Data.(partitioned).Key }), WithWorkers(4), WithBuffer(16))
+
+	for i := 0; i < n; i++ {
+		bus.PublishAsync(TopicAsyncOrdered, i)
+		bus.PublishAsync(TopicAsyncPartition, partitioned{Key: strconv.Itoa(i % 8), Seq: i})
+	}
+	// Close 等待队列中的事件处理完成
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ordered) != n {
+		t.Fatalf("expected %d ordered events, got %d", n, len(ordered))
+	}
+	for i, v := range ordered {
+		if v != i {
+			t.Fatalf("ordered events out of position at %d: got %d", i, v)
+		}
+	}
+	for key, seqs := range byKey {
+		for i := 1; i < len(seqs); i++ {
+			if seqs[i] < seqs[i-1] {
+				t.Fatalf("key %s: events out of order: %v", key, seqs)
+			}
+		}
+	}
+}
+
+type partitioned struct {
+	Key string
+	Seq int
+}
+
+func TestPartitionKey(t *testing.T) {
+	topic := NewTopic[partitioned]("test.partitioned")
+	defer GetEventBus().Unsubscribe(topic.Topic())
+
+	var mu sync.Mutex
+	got := make(map[string][]int)
+	fastDone := make(chan struct{})
+	topic.Subscribe(func(ctx context.Context, e partitioned) {
+		if e.Key == "slow" && e.Seq == 0 {
+			// 不同 key 并行处理，slow 等待 fast 处理完成
+			select {
+			case <-fastDone:
+			case <-time.After(time.Second):
+				t.Error("partitions should run in parallel")
+			}
+		}
+		mu.Lock()
+		got[e.Key] = append(got[e.Key], e.Seq)
+		if e.Key == "fast" && len(got[e.Key]) == 5 {
+			close(fastDone)
+		}
+		mu.Unlock()
+	}, WithWorkers(8), PartitionBy(func(e partitioned) string { return e.Key }))
+
+	// 保证两个 key 分配到不同的分区
+	sub := GetEventBus().subs[topic.Topic()][0]
+	if sub.queue.lane(&Event{Data: partitioned{Key: "slow"}}) == sub.queue.lane(&Event{Data: partitioned{Key: "fast"}}) {
+		t.Skip("keys hashed to the same partition")
+	}
+
+	for i := 0; i < 5; i++ {
+		topic.Publish(context.Background(), partitioned{Key: "slow", Seq: i})
+		topic.Publish(context.Background(), partitioned{Key: "fast", Seq: i})
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	for key, seqs := range got {
+		if len(seqs) != 5 {
+			t.Errorf("key %s: expected 5 events, got %v", key, seqs)
+		}
+		for i, n := range seqs {
+			if n != i {
+				t.Errorf("key %s: events out of order: %v", key, seqs)
+				break
+			}
+		}
+	}
+}
+
+func TestPartitionDefaultWorkers(t *testing.T) {
+	o := newSubscribeOptions([]SubscribeOption{WithPartitionKey(func(*Event) string { return "" })})
+	if o.workers != runtime.GOMAXPROCS(0) {
+		t.Fatalf("expected GOMAXPROCS partitions, got %d", o.workers)
+	}
+	if o = newSubscribeOptions([]SubscribeOption{WithOrdered(), WithWorkers(4)}); o.workers != 1 {
+		t.Fatalf("ordered mode should use one worker, got %d", o.workers)
+	}
+}
+
+func openPebble(t *testing.T, dir string) *pebblekv.PebbleKV {
+	t.Helper()
+	kv, err := kvs.NewPebbleKV(kvs.NewPebbleConfig(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kv.(*pebblekv.PebbleKV)
+}
+
+func TestDurable(t *testing.T) {
+	dir := t.TempDir()
+	topic := NewTopic[orderCreated]("test.durable.order")
+	defer GetEventBus().Unsubscribe(topic.Topic())
+
+	kv := openPebble(t, dir)
+	d, err := NewDurable(GetEventBus(), kv, DurableOptions{RetryInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 普通订阅者照常收到事件
+	var live int32
+	topic.Subscribe(func(ctx context.Context, e orderCreated) { atomic.AddInt32(&live, 1) })
+
+	var mu sync.Mutex
+	var got []int
+	failed := false
+	cancel, err := SubscribeDurable(d, "audit", topic, func(ctx context.Context, e orderCreated) error {
+		mu.Lock()
+		defer mu.Unlock()
+		// 第 2 个事件第一次处理失败，应被重新投递
+		if e.ID == 2 && !failed {
+			failed = true
+			return errors.New("temporary failure")
+		}
+		// 第 3 个事件始终失败，模拟崩溃前未确认
+		if e.ID == 3 {
+			return errors.New("not acked")
+		}
+		got = append(got, e.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = SubscribeDurable(d, "audit", topic, func(ctx context.Context, e orderCreated) error { return nil }); !errors.Is(err, errs.ErrConsumerExists) {
+		t.Fatalf("expected ErrConsumerExists, got %v", err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		if _, err = d.Publish(context.Background(), topic.Topic(), orderCreated{ID: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	mu.Lock()
+	if len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("unexpected events %v", got)
+	}
+	mu.Unlock()
+	if atomic.LoadInt32(&live) != 3 {
+		t.Errorf("expected 3 live events, got %d", live)
+	}
+	if acked, _ := d.Acked("audit", topic.Topic()); acked != 2 {
+		t.Errorf("expected acked offset 2, got %d", acked)
+	}
+	_ = d.Close()
+	_ = kv.Close()
+
+	// 重启后从上次确认的位点继续，未确认的事件重新投递
+	kv = openPebble(t, dir)
+	defer kv.Close()
+	d, err = NewDurable(GetEventBus(), kv, DurableOptions{RetryInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	got = nil
+	_, err = SubscribeDurable(d, "audit", topic, func(ctx context.Context, e orderCreated) error {
+		mu.Lock()
+		got = append(got, e.ID)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset, err := d.Publish(context.Background(), topic.Topic(), orderCreated{ID: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != 4 {
+		t.Errorf("expected offset 4, got %d", offset)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	if len(got) != 2 || got[0] != 3 || got[1] != 4 {
+		t.Errorf("unexpected events after restart %v", got)
+	}
+	mu.Unlock()
+
+	if n, err := d.Trim(topic.Topic()); err != nil || n != 4 {
+		t.Errorf("expected 4 trimmed events, got %d %v", n, err)
+	}
+
+	// 尚未确认任何事件的订阅者同样阻止删除
+	_, err = d.Subscribe("lagging", topic.Topic(), func(*DurableEvent) error {
+		return errors.New("not ready")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = d.Publish(context.Background(), topic.Topic(), orderCreated{ID: 5}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n, err := d.Trim(topic.Topic()); err != nil || n != 0 {
+		t.Errorf("expected nothing trimmed before lagging consumer acks, got %d %v", n, err)
+	}
+}
+
+func TestRetryAndDeadLetter(t *testing.T) {
+	bus := GetEventBus()
+	const TopicRetry Topic = 107
+	defer bus.Unsubscribe(TopicRetry)
+
+	dead := make(chan DeadLetter, 1)
+	cancelDead := bus.Subscribe(DeadLetterTopic, func(e *Event) {
+		if dl := e.Data.(DeadLetter); dl.Topic == TopicRetry {
+			dead <- dl
+		}
+	})
+	defer cancelDead()
+
+	var calls int32
+	healthy := int32(0)
+	bus.SubscribeE(TopicRetry, func(e *Event) error {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&healthy) == 1 {
+			return nil
+		}
+		return errors.New("downstream unavailable")
+	}, WithRetry(2, ExponentialBackoff(time.Millisecond, 4*time.Millisecond)))
+
+	bus.Publish(TopicRetry, "order-1")
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("expected 3 attempts, got %d", n)
+	}
+
+	var dl DeadLetter
+	select {
+	case dl = <-dead:
+	case <-time.After(time.Second):
+		t.Fatal("dead letter not published")
+	}
+	if dl.Data != "order-1" || dl.Attempts != 3 || dl.Err == nil {
+		t.Errorf("unexpected dead letter %+v", dl)
+	}
+
+	found := false
+	for _, item := range bus.DeadLetters() {
+		found = found || item.ID == dl.ID
+	}
+	if !found {
+		t.Fatal("dead letter should be kept for inspection")
+	}
+
+	// 恢复后重放成功，死信被移除
+	atomic.StoreInt32(&healthy, 1)
+	if err := bus.ReplayDeadLetter(context.Background(), dl.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range bus.DeadLetters() {
+		if item.ID == dl.ID {
+			t.Error("replayed dead letter should be removed")
+		}
+	}
+	if err := bus.ReplayDeadLetter(context.Background(), dl.ID); !errors.Is(err, errs.ErrKeyNotFound) {
+		t.Errorf("expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestPanicDeadLetter(t *testing.T) {
+	bus := GetEventBus()
+	const TopicPanicDead Topic = 108
+	defer bus.Unsubscribe(TopicPanicDead)
+
+	bus.Subscribe(TopicPanicDead, func(e *Event) {
+		panic("boom")
+	})
+	bus.Publish(TopicPanicDead, "data")
+
+	var dl *DeadLetter
+	for _, item := range bus.DeadLetters() {
+		if item.Topic == TopicPanicDead {
+			dl = &item
+		}
+	}
+	if dl == nil || !errors.Is(dl.Err, errs.ErrSubsPanic) {
+		t.Fatalf("expected panic dead letter, got %+v", dl)
+	}
+
+	// 订阅者取消后无法重放，死信保留
+	bus.Unsubscribe(TopicPanicDead)
+	if err := bus.ReplayDeadLetter(context.Background(), dl.ID); !errors.Is(err, errs.ErrSubscriberNotFound) {
+		t.Errorf("expected ErrSubscriberNotFound, got %v", err)
+	}
+	if !bus.DiscardDeadLetter(dl.ID) {
+		t.Error("dead letter should be kept after failed replay")
+	}
+}
+
+// testBridge 模拟两个副本分别通过 transportA、transportB 同步主题
+func testBridge(t *testing.T, transportA, transportB Transport) {
+	t.Helper()
+	busA, busB := NewEventBus(), NewEventBus()
+	topicA := NewTopicOn[orderCreated](busA, "test.bridge.order")
+	topicB := NewTopicOn[orderCreated](busB, "test.bridge.order")
+
+	bridgeA, bridgeB := NewBridge(transportA), NewBridge(transportB)
+	defer bridgeA.Close()
+	defer bridgeB.Close()
+	if err := BridgeTopic(bridgeA, topicA, codec.JSON[orderCreated]()); err != nil {
+		t.Fatal(err)
+	}
+	if err := BridgeTopic(bridgeB, topicB, codec.JSON[orderCreated]()); err != nil {
+		t.Fatal(err)
+	}
+
+	var countA, countB int32
+	var remote atomic.Bool
+	topicA.Subscribe(func(ctx context.Context, e orderCreated) { atomic.AddInt32(&countA, 1) })
+	topicB.Subscribe(func(ctx context.Context, e orderCreated) {
+		if e.ID == 7 {
+			remote.Store(IsRemote(ctx))
+			atomic.AddInt32(&countB, 1)
+		}
+	})
+
+	topicA.Publish(context.Background(), orderCreated{ID: 7, Amount: 1.5})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&countB) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if countB != 1 || !remote.Load() {
+		t.Errorf("expected 1 remote event on replica B, got %d remote=%v", countB, remote.Load())
+	}
+	// 自身发出的事件不会回环
+	if countA != 1 {
+		t.Errorf("expected 1 event on replica A, got %d", countA)
+	}
+}
+
+func TestBridgeMemory(t *testing.T) {
+	transport := NewMemoryTransport()
+	testBridge(t, transport, transport)
+}
+
+func TestRedisTransportConsumerRequired(t *testing.T) {
+	// 设置 Group 时必须指定固定的 Consumer，校验先于访问 Redis
+	cli := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1"})
+	defer cli.Close()
+	transport := NewRedisTransport(cli, RedisTransportOptions{Group: "replicas"})
+	defer transport.Close()
+	if _, err := transport.Subscribe("test.bridge.order", func(Message) {}); !errors.Is(err, errs.ErrConsumerRequired) {
+		t.Errorf("expected ErrConsumerRequired, got %v", err)
+	}
+}
+
+func TestBridgeRedis(t *testing.T) {
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("set TEST_REDIS_ADDR to run redis integration tests")
+	}
+	cli := redis.NewClient(&redis.Options{Addr: addr, Password: os.Getenv("TEST_REDIS_PASSWORD")})
+	defer cli.Close()
+	if err := cli.Ping(context.Background()).Err(); err != nil {
+		t.Skip("redis not available:", err)
+	}
+	transport := NewRedisTransport(cli, RedisTransportOptions{Prefix: "eventbus:test:", Block: 100 * time.Millisecond})
+	defer transport.Close()
+	testBridge(t, transport, transport)
+
+	// 两个副本配置相同的 Group，发布方读到自身事件不影响其他副本
+	prefix := "eventbus:test:" + gen.ShortID() + ":"
+	transportA := NewRedisTransport(cli, RedisTransportOptions{Prefix: prefix, Group: "replicas", Consumer: "a", Block: 100 * time.Millisecond})
+	transportB := NewRedisTransport(cli, RedisTransportOptions{Prefix: prefix, Group: "replicas", Consumer: "b", Block: 100 * time.Millisecond})
+	defer transportA.Close()
+	defer transportB.Close()
+	defer cli.Del(context.Background(), prefix+"test.bridge.order")
+	testBridge(t, transportA, transportB)
+}
+
+func TestPublishCtxAndMiddleware(t *testing.T) {
+	bus := NewEventBus()
+	defer bus.Close(context.Background())
+	const TopicMiddleware Topic = 1
+
+	var order []string
+	var mu sync.Mutex
+	mark := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(e *Event) error {
+				mu.Lock()
+				order = append(order, name)
+				mu.Unlock()
+				return next(e)
+			}
+		}
+	}
+	var observed int32
+	bus.Use(mark("bus"), Metrics(func(topic Topic, cost time.Duration, err error) {
+		atomic.AddInt32(&observed, 1)
+	}))
+
+	var traceID any
+	bus.Subscribe(TopicMiddleware, func(e *Event) {
+		traceID = e.Context().Value(ctxKey{})
+	}, WithMiddleware(mark("sub"), Logging()))
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "trace-2")
+	if err := bus.PublishCtx(ctx, TopicMiddleware, "data"); err != nil {
+		t.Fatal(err)
+	}
+	if traceID != "trace-2" {
+		t.Errorf("context not propagated, got %v", traceID)
+	}
+	if len(order) != 2 || order[0] != "bus" || order[1] != "sub" || observed != 1 {
+		t.Errorf("unexpected middleware order %v observed=%d", order, observed)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := bus.PublishCtx(cancelled, TopicMiddleware, "data"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	bus := NewEventBus()
+	defer bus.Close(context.Background())
+	const TopicTimeout Topic = 1
+
+	bus.SubscribeE(TopicTimeout, func(e *Event) error {
+		<-e.Context().Done()
+		return nil
+	}, WithMiddleware(Timeout(20*time.Millisecond)))
+
+	start := time.Now()
+	bus.Publish(TopicTimeout, "slow")
+	if time.Since(start) > 500*time.Millisecond {
+		t.Error("timeout middleware did not cancel the handler")
+	}
+	dls := bus.DeadLetters()
+	if len(dls) != 1 || !errors.Is(dls[0].Err, context.DeadlineExceeded) {
+		t.Errorf("expected timeout dead letter, got %+v", dls)
+	}
+
+	// 顺序模式下超时的订阅函数返回前，不会开始处理下一个事件
+	const TopicOrderedTimeout Topic = 2
+	var running, overlapped int32
+	done := make(chan struct{}, 2)
+	bus.SubscribeE(TopicOrderedTimeout, func(e *Event) error {
+		if atomic.AddInt32(&running, 1) > 1 {
+			atomic.StoreInt32(&overlapped, 1)
+		}
+		time.Sleep(40 * time.Millisecond) // 不响应取消
+		atomic.AddInt32(&running, -1)
+		done <- struct{}{}
+		return nil
+	}, WithOrdered(), WithMiddleware(Timeout(10*time.Millisecond)))
+	bus.Publish(TopicOrderedTimeout, 1)
+	bus.Publish(TopicOrderedTimeout, 2)
+	for range 2 {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for ordered events")
+		}
+	}
+	if atomic.LoadInt32(&overlapped) != 0 {
+		t.Error("ordered events handled concurrently after timeout")
+	}
+}
+
+func TestClose(t *testing.T) {
+	bus := NewEventBus()
+	const TopicClose Topic = 1
+
+	var handled int32
+	bus.Subscribe(TopicClose, func(e *Event) {
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&handled, 1)
+	}, WithBuffer(100))
+	bus.Subscribe(TopicClose, func(e *Event) {
+		atomic.AddInt32(&handled, 1)
+	})
+
+	for i := 0; i < 10; i++ {
+		bus.PublishAsync(TopicClose, i)
+	}
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// 关闭前发布的事件全部处理完成
+	if n := atomic.LoadInt32(&handled); n != 20 {
+		t.Errorf("expected 20 handled events, got %d", n)
+	}
+	if err := bus.PublishCtx(context.Background(), TopicClose, "late"); !errors.Is(err, errs.ErrBusClosed) {
+		t.Errorf("expected ErrBusClosed, got %v", err)
+	}
+
+	// 关闭后的订阅不会启动 worker
+	before, count := runtime.NumGoroutine(), bus.SubscriberCount(TopicClose)
+	cancelLate := bus.Subscribe(TopicClose, func(e *Event) {}, WithBuffer(10), WithWorkers(4))
+	cancelPattern := bus.SubscribePattern("test.#", func(e *Event) {}, WithBuffer(10), WithWorkers(4))
+	if n := runtime.NumGoroutine() - before; n > 0 || bus.SubscriberCount(TopicClose) != count {
+		t.Errorf("expected no subscription after close, got %d extra goroutines", n)
+	}
+	cancelLate()
+	cancelPattern()
+
+	// 超时后不再等待
+	bus = NewEventBus()
+	block := make(chan struct{})
+	defer close(block)
+	bus.Subscribe(TopicClose, func(e *Event) { <-block }, WithBuffer(10))
+	bus.Publish(TopicClose, 1)
+	bus.Publish(TopicClose, 2)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := bus.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected DeadlineExceeded, got %v", err)
+	}
+}
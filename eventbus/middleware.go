@@ -0,0 +1,72 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lance4117/gofuse/logger"
+)
+
+// Handler 订阅函数
+type Handler func(event *Event) error
+
+// Middleware 订阅函数中间件
+type Middleware func(next Handler) Handler
+
+// chain 按顺序包装中间件，mws[0] 在最外层
+func chain(h Handler, mws []Middleware) Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// Logging 记录每次处理的主题、耗时与错误
+func Logging() Middleware {
+	return func(next Handler) Handler {
+		return func(e *Event) error {
+			start := time.Now()
+			err := next(e)
+			if err != nil {
+				logger.Warnf("eventbus: topic=%s cost=%s err=%v", e.Topic, time.Since(start), err)
+			} else {
+				logger.Debugf("eventbus: topic=%s cost=%s", e.Topic, time.Since(start))
+			}
+			return err
+		}
+	}
+}
+
+// Metrics 每次处理完成后回调 observe，可用于上报耗时与失败次数
+func Metrics(observe func(topic Topic, cost time.Duration, err error)) Middleware {
+	return func(next Handler) Handler {
+		return func(e *Event) error {
+			start := time.Now()
+			err := next(e)
+			observe(e.Topic, time.Since(start), err)
+			return err
+		}
+	}
+}
+
+// Timeout 限制单次处理的时间，超时后取消 Event.Context，处理结果视为 context.DeadlineExceeded；
+// 订阅函数无法被强制中止，需自行响应 Event.Context 的取消。
+// 中间件会等待订阅函数返回，保证顺序及分区模式下同一 key 的事件不会并发处理
+func Timeout(d time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return func(e *Event) error {
+			ctx, cancel := context.WithTimeout(e.Context(), d)
+			defer cancel()
+			ev := *e
+			ev.ctx = ctx
+
+			err := next(&ev)
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("eventbus: topic=%s handler: %w", e.Topic, errors.Join(ctx.Err(), err))
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package eventbus
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/lance4117/gofuse/pool"
+)
+
+// OverflowPolicy 订阅者队列已满时的处理策略
+type OverflowPolicy int
+
+const (
+	OverflowBlock      OverflowPolicy = iota // 阻塞发布者，直到队列有空位或发布的 context 结束
+	OverflowDropOldest                       // 丢弃队列中最早的事件
+	OverflowDropNewest                       // 丢弃当前发布的事件
+	OverflowError                            // 丢弃当前发布的事件，并通过 TryPublish 返回 errs.ErrQueueFull
+)
+
+// SubscribeOption 订阅选项
+type SubscribeOption func(*subscribeOptions)
+
+// defaultBufferSize 顺序、分区模式下未指定队列容量时的默认值
+const defaultBufferSize = 1024
+
+type subscribeOptions struct {
+	bufferSize  int
+	overflow    OverflowPolicy
+	workers     int
+	pool        *pool.Pool
+	ordered     bool
+	key         func(*Event) string
+	retries     int
+	backoff     Backoff
+	middlewares []Middleware
+}
+
+// Backoff 重试等待策略，attempt 为第几次重试，从 1 开始
+type Backoff func(attempt int) time.Duration
+
+// ConstantBackoff 每次重试等待固定时间
+func ConstantBackoff(d time.Duration) Backoff {
+	return func(int) time.Duration {
+		return d
+	}
+}
+
+// ExponentialBackoff 等待时间从 base 开始逐次翻倍，不超过 max
+func ExponentialBackoff(base, max time.Duration) Backoff {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		return min(d, max)
+	}
+}
+
+// WithBuffer 为订阅者分配容量为 size 的有界队列，发布时只入队不等待处理；
+// 不设置时每次发布为每个订阅者启动一个 goroutine 并等待处理完成
+func WithBuffer(size int) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.bufferSize = size
+	}
+}
+
+// WithOverflow 设置队列已满时的处理策略，默认 OverflowBlock
+func WithOverflow(policy OverflowPolicy) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.overflow = policy
+	}
+}
+
+// WithWorkers 设置消费队列的并发数，默认 1，分区模式下默认 GOMAXPROCS
+func WithWorkers(n int) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.workers = n
+	}
+}
+
+// WithPool 在指定的 pool.Pool 中执行订阅函数，多个订阅者可共享同一个池以限制总并发
+func WithPool(p *pool.Pool) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.pool = p
+	}
+}
+
+// WithOrdered 顺序模式，订阅者按发布顺序逐个处理事件；
+// 事件经队列异步处理，未设置 WithBuffer 时队列容量为 1024
+func WithOrdered() SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.ordered = true
+	}
+}
+
+// WithPartitionKey 分区模式，按 key 将事件分配到 WithWorkers 个分区，未设置时为 GOMAXPROCS 个，
+// 同一 key 的事件按发布顺序处理，不同 key 的事件并行处理；
+// 每个分区一个队列，未设置 WithBuffer 时容量为 1024
+func WithPartitionKey(key func(e *Event) string) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.key = key
+	}
+}
+
+// PartitionBy 同 WithPartitionKey，按事件数据计算 key，便于与 TypedTopic 配合使用；
+// 数据类型不匹配的事件统一分配到空 key
+func PartitionBy[T any](key func(data T) string) SubscribeOption {
+	return WithPartitionKey(func(e *Event) string {
+		data, ok := e.Data.(T)
+		if !ok {
+			return ""
+		}
+		return key(data)
+	})
+}
+
+// WithRetry 订阅函数返回错误或 panic 时最多重试 max 次，backoff 为空时立即重试；
+// 超过重试次数仍失败的事件转入死信，见 DeadLetterTopic。
+// 未使用队列的订阅者在发布方的调用中重试，发布方会等待重试结束
+func WithRetry(max int, backoff Backoff) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.retries = max
+		o.backoff = backoff
+	}
+}
+
+// WithMiddleware 为订阅者添加中间件，先添加的在外层
+func WithMiddleware(mws ...Middleware) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.middlewares = append(o.middlewares, mws...)
+	}
+}
+
+func newSubscribeOptions(opts []SubscribeOption) subscribeOptions {
+	var o subscribeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	switch {
+	case o.ordered:
+		o.workers = 1
+	case o.workers <= 0 && o.key != nil:
+		o.workers = runtime.GOMAXPROCS(0)
+	case o.workers <= 0:
+		o.workers = 1
+	}
+	if (o.ordered || o.key != nil) && o.bufferSize <= 0 {
+		o.bufferSize = defaultBufferSize
+	}
+	return o
+}
@@ -0,0 +1,91 @@
+package eventbus
+
+import "strings"
+
+const (
+	wildcardOne  = "*" // 匹配一段
+	wildcardMany = "#" // 匹配零段或多段
+)
+
+// patternNode 通配订阅前缀树节点，主题名称按 "." 分段
+type patternNode struct {
+	children map[string]*patternNode
+	subs     []*subscriber
+}
+
+// patternTrie 通配订阅前缀树，发布时按主题名称逐段匹配，与订阅数量无关
+type patternTrie struct {
+	root  patternNode
+	count int
+}
+
+func (t *patternTrie) add(pattern string, sub *subscriber) {
+	n := &t.root
+	for _, seg := range strings.Split(pattern, ".") {
+		if n.children == nil {
+			n.children = make(map[string]*patternNode)
+		}
+		child, ok := n.children[seg]
+		if !ok {
+			child = &patternNode{}
+			n.children[seg] = child
+		}
+		n = child
+	}
+	n.subs = append(n.subs, sub)
+	t.count++
+}
+
+func (t *patternTrie) remove(pattern string, id int) {
+	t.removeAt(&t.root, strings.Split(pattern, "."), id)
+}
+
+// removeAt 删除订阅者，并回收空节点
+func (t *patternTrie) removeAt(n *patternNode, segs []string, id int) bool {
+	if len(segs) == 0 {
+		for i, s := range n.subs {
+			if s.id == id {
+				n.subs = append(n.subs[:i], n.subs[i+1:]...)
+				t.count--
+				break
+			}
+		}
+	} else if child, ok := n.children[segs[0]]; ok && t.removeAt(child, segs[1:], id) {
+		delete(n.children, segs[0])
+	}
+	return len(n.subs) == 0 && len(n.children) == 0
+}
+
+// match 返回与主题名称匹配的订阅者
+func (t *patternTrie) match(name string) []*subscriber {
+	if t.count == 0 || name == "" {
+		return nil
+	}
+	var out []*subscriber
+	seen := make(map[int]struct{})
+	var walk func(n *patternNode, segs []string)
+	walk = func(n *patternNode, segs []string) {
+		if many, ok := n.children[wildcardMany]; ok {
+			for i := 0; i <= len(segs); i++ {
+				walk(many, segs[i:])
+			}
+		}
+		if len(segs) == 0 {
+			for _, s := range n.subs {
+				if _, ok := seen[s.id]; !ok {
+					seen[s.id] = struct{}{}
+					out = append(out, s)
+				}
+			}
+			return
+		}
+		if child, ok := n.children[segs[0]]; ok {
+			walk(child, segs[1:])
+		}
+		if one, ok := n.children[wildcardOne]; ok {
+			walk(one, segs[1:])
+		}
+	}
+	walk(&t.root, strings.Split(name, "."))
+	return out
+}
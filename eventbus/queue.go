@@ -0,0 +1,141 @@
+package eventbus
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"github.com/lance4117/gofuse/errs"
+	"github.com/lance4117/gofuse/logger"
+)
+
+// subQueue 订阅者的有界队列，由固定数量的 worker 消费；
+// 分区模式下每个 worker 独占一个队列，否则所有 worker 共享一个队列
+type subQueue struct {
+	sub      *subscriber
+	lanes    []chan *Event
+	key      func(*Event) string
+	overflow OverflowPolicy
+	done     <-chan struct{}
+	wg       sync.WaitGroup // 运行中的 worker
+}
+
+func newSubQueue(sub *subscriber, opts subscribeOptions) *subQueue {
+	q := &subQueue{
+		sub:      sub,
+		key:      opts.key,
+		overflow: opts.overflow,
+		done:     sub.done,
+	}
+	if q.key == nil {
+		ch := make(chan *Event, opts.bufferSize)
+		q.lanes = []chan *Event{ch}
+		q.wg.Add(opts.workers)
+		for i := 0; i < opts.workers; i++ {
+			go q.work(ch)
+		}
+		return q
+	}
+	q.lanes = make([]chan *Event, opts.workers)
+	q.wg.Add(opts.workers)
+	for i := range q.lanes {
+		q.lanes[i] = make(chan *Event, opts.bufferSize)
+		go q.work(q.lanes[i])
+	}
+	return q
+}
+
+// lane 返回事件所属的队列
+func (q *subQueue) lane(e *Event) chan *Event {
+	if len(q.lanes) == 1 {
+		return q.lanes[0]
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(q.key(e)))
+	return q.lanes[h.Sum32()%uint32(len(q.lanes))]
+}
+
+// push 按溢出策略将事件入队
+func (q *subQueue) push(e *Event) error {
+	ch := q.lane(e)
+	select {
+	case ch <- e:
+		return nil
+	case <-q.done:
+		return nil
+	default:
+	}
+
+	switch q.overflow {
+	case OverflowDropNewest:
+		return nil
+	case OverflowError:
+		return fmt.Errorf("%w: topic=%s subscriber_id=%d", errs.ErrQueueFull, e.Topic, q.sub.id)
+	case OverflowDropOldest:
+		for {
+			select {
+			case ch <- e:
+				return nil
+			case <-q.done:
+				return nil
+			default:
+			}
+			select {
+			case <-ch:
+			default:
+			}
+		}
+	default:
+		ctx := e.Context()
+		select {
+		case ch <- e:
+			return nil
+		case <-q.done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (q *subQueue) work(ch chan *Event) {
+	defer q.wg.Done()
+	for {
+		select {
+		case <-q.done:
+			return
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			q.sub.call(e)
+		}
+	}
+}
+
+// drain 关闭队列并等待 worker 处理完剩余事件，调用前需确保不再有事件入队
+func (q *subQueue) drain() {
+	for _, ch := range q.lanes {
+		close(ch)
+	}
+	q.wg.Wait()
+}
+
+// call 处理事件，配置了 pool 时在池中执行并等待完成
+func (s *subscriber) call(e *Event) {
+	if s.pool == nil {
+		s.handle(e)
+		return
+	}
+	done := make(chan struct{})
+	if err := s.pool.Go(func() {
+		defer close(done)
+		s.handle(e)
+	}); err != nil {
+		// 池已释放等情况下退化为直接执行
+		logger.Warnf("eventbus: topic=%s subscriber_id=%d submit to pool: %v", e.Topic, s.id, err)
+		s.handle(e)
+		return
+	}
+	<-done
+}
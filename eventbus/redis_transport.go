@@ -0,0 +1,191 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lance4117/gofuse/errs"
+	"github.com/lance4117/gofuse/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisTransportOptions Redis Streams 传输配置
+type RedisTransportOptions struct {
+	Prefix   string                    // stream key 前缀，默认 "eventbus:"
+	Stream   func(topic string) string // 可选，自定义主题到 stream key 的映射，设置后忽略 Prefix
+	Group    string                    // 消费组名称前缀，设置后每个实例使用独立的消费组 Group:Consumer，重启后从未确认的事件继续
+	Consumer string                    // 实例名称，设置 Group 时必填，且需在重启后保持不变
+	MaxLen   int64                     // stream 近似最大长度，默认 10000
+	Block    time.Duration             // 单次阻塞读取的最长时间，默认 5s
+}
+
+// RedisTransport 基于 Redis Streams 的传输通道，每个主题对应一个 stream
+type RedisTransport struct {
+	cli  *redis.Client
+	opts RedisTransportOptions
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRedisTransport 创建基于 Redis Streams 的传输通道，cli 可复用 rediskv.RedisStore.RedisCli
+func NewRedisTransport(cli *redis.Client, opts RedisTransportOptions) *RedisTransport {
+	if opts.Prefix == "" {
+		opts.Prefix = "eventbus:"
+	}
+	if opts.MaxLen <= 0 {
+		opts.MaxLen = 10000
+	}
+	if opts.Block <= 0 {
+		opts.Block = 5 * time.Second
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &RedisTransport{cli: cli, opts: opts, ctx: ctx, cancel: cancel}
+}
+
+func (t *RedisTransport) stream(topic string) string {
+	if t.opts.Stream != nil {
+		return t.opts.Stream(topic)
+	}
+	return t.opts.Prefix + topic
+}
+
+// Publish 追加事件到主题对应的 stream
+func (t *RedisTransport) Publish(ctx context.Context, msg Message) error {
+	return t.cli.XAdd(ctx, &redis.XAddArgs{
+		Stream: t.stream(msg.Topic),
+		MaxLen: t.opts.MaxLen,
+		Approx: true,
+		Values: map[string]any{"origin": msg.Origin, "data": msg.Data},
+	}).Err()
+}
+
+// group 当前实例使用的消费组；每个实例独占一个消费组，保证所有实例都收到全部事件，
+// 共享消费组时事件只投递给其中一个实例，若恰好是发布方自己，其他实例将收不到该事件
+func (t *RedisTransport) group() string {
+	return t.opts.Group + ":" + t.opts.Consumer
+}
+
+// Subscribe 从主题对应的 stream 读取订阅之后的新事件，返回取消订阅函数；
+// 配置了 Group 时通过当前实例的消费组读取，处理完成后确认，未设置 Consumer 时返回 errs.ErrConsumerRequired
+func (t *RedisTransport) Subscribe(topic string, fn func(msg Message)) (func(), error) {
+	if t.opts.Group != "" && t.opts.Consumer == "" {
+		// 随机名称在每次重启后都会创建新的消费组，且无法从未确认的事件继续
+		return nil, errs.ErrConsumerRequired
+	}
+	stream := t.stream(topic)
+	// 订阅返回前确定起始位置，避免遗漏订阅之后、开始读取之前发布的事件
+	last := "0-0"
+	if t.opts.Group != "" {
+		err := t.cli.XGroupCreateMkStream(t.ctx, stream, t.group(), "$").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return nil, err
+		}
+	} else {
+		msgs, err := t.cli.XRevRangeN(t.ctx, stream, "+", "-", 1).Result()
+		if err != nil {
+			return nil, err
+		}
+		if len(msgs) > 0 {
+			last = msgs[0].ID
+		}
+	}
+
+	ctx, cancel := context.WithCancel(t.ctx)
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		if t.opts.Group != "" {
+			t.readGroup(ctx, topic, stream, fn)
+		} else {
+			t.read(ctx, topic, stream, last, fn)
+		}
+	}()
+	return cancel, nil
+}
+
+// read 不使用消费组，读取 last 之后的事件
+func (t *RedisTransport) read(ctx context.Context, topic, stream, last string, fn func(msg Message)) {
+	for ctx.Err() == nil {
+		res, err := t.cli.XRead(ctx, &redis.XReadArgs{
+			Streams: []string{stream, last},
+			Block:   t.opts.Block,
+		}).Result()
+		if err != nil {
+			t.wait(ctx, stream, err)
+			continue
+		}
+		for _, s := range res {
+			for _, m := range s.Messages {
+				last = m.ID
+				fn(toMessage(topic, m))
+			}
+		}
+	}
+}
+
+// readGroup 通过当前实例的消费组读取，先处理未确认的事件，再读取新事件
+func (t *RedisTransport) readGroup(ctx context.Context, topic, stream string, fn func(msg Message)) {
+	pending := true
+	for ctx.Err() == nil {
+		id := ">"
+		if pending {
+			id = "0"
+		}
+		res, err := t.cli.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    t.group(),
+			Consumer: t.opts.Consumer,
+			Streams:  []string{stream, id},
+			Block:    t.opts.Block,
+		}).Result()
+		if err != nil {
+			t.wait(ctx, stream, err)
+			continue
+		}
+		n := 0
+		for _, s := range res {
+			for _, m := range s.Messages {
+				n++
+				fn(toMessage(topic, m))
+				if err = t.cli.XAck(ctx, stream, t.group(), m.ID).Err(); err != nil && ctx.Err() == nil {
+					logger.Errorf("eventbus: redis transport ack stream=%s id=%s: %v", stream, m.ID, err)
+				}
+			}
+		}
+		if pending && n == 0 {
+			pending = false
+		}
+	}
+}
+
+// wait 读取失败时记录错误并稍后重试，超时与取消不视为错误
+func (t *RedisTransport) wait(ctx context.Context, stream string, err error) {
+	if errors.Is(err, redis.Nil) || ctx.Err() != nil {
+		return
+	}
+	logger.Errorf("eventbus: redis transport read stream=%s: %v", stream, err)
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+	}
+}
+
+func toMessage(topic string, m redis.XMessage) Message {
+	msg := Message{Topic: topic}
+	msg.Origin, _ = m.Values["origin"].(string)
+	if data, ok := m.Values["data"].(string); ok {
+		msg.Data = []byte(data)
+	}
+	return msg
+}
+
+// Close 停止所有订阅，不关闭 Redis 客户端
+func (t *RedisTransport) Close() error {
+	t.cancel()
+	t.wg.Wait()
+	return nil
+}
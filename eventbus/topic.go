@@ -0,0 +1,76 @@
+package eventbus
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/lance4117/gofuse/errs"
+)
+
+// topicBase 按名称自动分配的主题起始值，避开业务使用 iota 定义的常量
+const topicBase Topic = 1 << 30
+
+// topicRegistry 主题名称登记表
+var topicRegistry = struct {
+	mu     sync.RWMutex
+	byName map[string]Topic
+	names  map[Topic]string
+	next   Topic
+}{
+	byName: make(map[string]Topic),
+	names:  make(map[Topic]string),
+	next:   topicBase,
+}
+
+// TopicOf 返回名称对应的主题，不存在时自动分配，同名主题在进程内唯一
+func TopicOf(name string) Topic {
+	topicRegistry.mu.RLock()
+	topic, ok := topicRegistry.byName[name]
+	topicRegistry.mu.RUnlock()
+	if ok {
+		return topic
+	}
+
+	topicRegistry.mu.Lock()
+	defer topicRegistry.mu.Unlock()
+	// double check
+	if topic, ok = topicRegistry.byName[name]; ok {
+		return topic
+	}
+	topic = topicRegistry.next
+	topicRegistry.next++
+	topicRegistry.byName[name] = topic
+	topicRegistry.names[topic] = name
+	return topic
+}
+
+// RegisterTopic 为使用 iota 定义的主题登记名称，便于日志输出；
+// 名称已属于其他主题时返回 errs.ErrTopicNameTaken，重复登记同一主题时覆盖旧名称
+func RegisterTopic(topic Topic, name string) error {
+	topicRegistry.mu.Lock()
+	defer topicRegistry.mu.Unlock()
+	if owner, ok := topicRegistry.byName[name]; ok && owner != topic {
+		return errs.ErrTopicNameTaken
+	}
+	if old, ok := topicRegistry.names[topic]; ok {
+		delete(topicRegistry.byName, old)
+	}
+	topicRegistry.byName[name] = topic
+	topicRegistry.names[topic] = name
+	return nil
+}
+
+// Name 返回主题名称，未登记时为空
+func (t Topic) Name() string {
+	topicRegistry.mu.RLock()
+	defer topicRegistry.mu.RUnlock()
+	return topicRegistry.names[t]
+}
+
+// String 返回主题名称，未登记时返回数字
+func (t Topic) String() string {
+	if name := t.Name(); name != "" {
+		return name
+	}
+	return strconv.Itoa(int(t))
+}
@@ -0,0 +1,161 @@
+package eventbus
+
+import (
+	"context"
+	"sync"
+
+	"github.com/lance4117/gofuse/codec"
+	"github.com/lance4117/gofuse/gen"
+	"github.com/lance4117/gofuse/logger"
+)
+
+// Message 跨实例传输的事件
+type Message struct {
+	Origin string // 发出事件的实例标识，用于忽略自身发出的事件
+	Topic  string // 主题名称
+	Data   []byte // 编码后的事件数据
+}
+
+// Transport 跨实例的事件传输通道
+type Transport interface {
+	// Publish 将事件发送给其他实例
+	Publish(ctx context.Context, msg Message) error
+	// Subscribe 接收指定主题的事件，返回取消订阅函数
+	Subscribe(topic string, fn func(msg Message)) (func(), error)
+	// Close 关闭传输通道
+	Close() error
+}
+
+// remoteKey 标记由其他实例转发而来的事件，避免再次转发
+type remoteKey struct{}
+
+// IsRemote 判断事件是否由其他实例经 Bridge 转发而来
+func IsRemote(ctx context.Context) bool {
+	remote, _ := ctx.Value(remoteKey{}).(bool)
+	return remote
+}
+
+// Bridge 通过 Transport 在多个实例之间同步主题事件：
+// 本地发布的事件编码后发送给其他实例，其他实例的事件解码后在本地发布，自身发出的事件会被忽略
+type Bridge struct {
+	transport Transport
+	id        string
+
+	mu      sync.Mutex
+	cancels []func()
+}
+
+// NewBridge 创建跨实例桥接，transport 的生命周期由调用方管理
+func NewBridge(transport Transport) *Bridge {
+	return &Bridge{transport: transport, id: gen.ShortID()}
+}
+
+// ID 返回实例标识
+func (b *Bridge) ID() string {
+	return b.id
+}
+
+// BridgeTopic 在实例之间同步带类型的主题，c 为空时使用 msgpack 编码；
+// opts 作用于转发本地事件的订阅者，如 WithBuffer 可避免发布方等待网络发送
+func BridgeTopic[T any](b *Bridge, topic *TypedTopic[T], c codec.Codec[T], opts ...SubscribeOption) error {
+	if c == nil {
+		c = codec.Msgpack[T]()
+	}
+	name := topic.Topic().String()
+
+	unsubscribe, err := b.transport.Subscribe(name, func(msg Message) {
+		if msg.Origin == b.id {
+			return
+		}
+		data, err := c.Unmarshal(msg.Data)
+		if err != nil {
+			logger.Errorf("eventbus: bridge decode topic=%s origin=%s: %v", name, msg.Origin, err)
+			return
+		}
+		if err = topic.TryPublish(context.WithValue(context.Background(), remoteKey{}, true), data); err != nil {
+			logger.Warnf("eventbus: bridge publish topic=%s origin=%s: %v", name, msg.Origin, err)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	cancel := topic.SubscribeE(func(ctx context.Context, data T) error {
+		if IsRemote(ctx) {
+			return nil
+		}
+		bytes, err := c.Marshal(data)
+		if err != nil {
+			return err
+		}
+		return b.transport.Publish(ctx, Message{Origin: b.id, Topic: name, Data: bytes})
+	}, opts...)
+
+	b.mu.Lock()
+	b.cancels = append(b.cancels, unsubscribe, cancel)
+	b.mu.Unlock()
+	return nil
+}
+
+// Close 停止同步所有主题，不关闭 transport
+func (b *Bridge) Close() error {
+	b.mu.Lock()
+	cancels := b.cancels
+	b.cancels = nil
+	b.mu.Unlock()
+	for _, cancel := range cancels {
+		cancel()
+	}
+	return nil
+}
+
+// MemoryTransport 进程内的传输通道，多个 Bridge 共享同一实例即可模拟多副本，便于测试
+type MemoryTransport struct {
+	mu     sync.RWMutex
+	subs   map[string]map[int]func(msg Message)
+	nextID int
+}
+
+// NewMemoryTransport 创建进程内的传输通道
+func NewMemoryTransport() *MemoryTransport {
+	return &MemoryTransport{subs: make(map[string]map[int]func(msg Message))}
+}
+
+// Publish 同步投递给所有订阅者
+func (t *MemoryTransport) Publish(ctx context.Context, msg Message) error {
+	t.mu.RLock()
+	fns := make([]func(msg Message), 0, len(t.subs[msg.Topic]))
+	for _, fn := range t.subs[msg.Topic] {
+		fns = append(fns, fn)
+	}
+	t.mu.RUnlock()
+	for _, fn := range fns {
+		fn(msg)
+	}
+	return nil
+}
+
+// Subscribe 接收指定主题的事件，返回取消订阅函数
+func (t *MemoryTransport) Subscribe(topic string, fn func(msg Message)) (func(), error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	id := t.nextID
+	t.nextID++
+	if t.subs[topic] == nil {
+		t.subs[topic] = make(map[int]func(msg Message))
+	}
+	t.subs[topic][id] = fn
+	return func() {
+		t.mu.Lock()
+		delete(t.subs[topic], id)
+		t.mu.Unlock()
+	}, nil
+}
+
+// Close 清空订阅者
+func (t *MemoryTransport) Close() error {
+	t.mu.Lock()
+	t.subs = make(map[string]map[int]func(msg Message))
+	t.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,71 @@
+package eventbus
+
+import (
+	"context"
+
+	"github.com/lance4117/gofuse/logger"
+)
+
+// TypedTopic 带类型的主题，发布与订阅的数据类型在编译期确定，避免订阅者手动断言
+type TypedTopic[T any] struct {
+	bus   *EventBus
+	topic Topic
+}
+
+// NewTopic 在全局事件总线上创建带类型的主题，同名主题共享订阅者
+func NewTopic[T any](name string) *TypedTopic[T] {
+	return NewTopicOn[T](GetEventBus(), name)
+}
+
+// NewTopicOn 在指定事件总线上创建带类型的主题
+func NewTopicOn[T any](bus *EventBus, name string) *TypedTopic[T] {
+	return &TypedTopic[T]{bus: bus, topic: TopicOf(name)}
+}
+
+// Topic 返回底层的主题
+func (t *TypedTopic[T]) Topic() Topic {
+	return t.topic
+}
+
+// Name 返回主题名称
+func (t *TypedTopic[T]) Name() string {
+	return t.topic.Name()
+}
+
+// Publish 发布事件，等待所有订阅者处理完成，失败时记录日志
+func (t *TypedTopic[T]) Publish(ctx context.Context, data T) {
+	if err := t.TryPublish(ctx, data); err != nil {
+		logger.Warnf("eventbus: publish topic=%s: %v", t.topic, err)
+	}
+}
+
+// TryPublish 同 Publish，返回错误，语义同 EventBus.PublishCtx
+func (t *TypedTopic[T]) TryPublish(ctx context.Context, data T) error {
+	return t.bus.PublishCtx(ctx, t.topic, data)
+}
+
+// PublishAsync 异步发布事件，不等待处理完成，语义同 EventBus.PublishAsyncCtx
+func (t *TypedTopic[T]) PublishAsync(ctx context.Context, data T) {
+	t.bus.PublishAsyncCtx(ctx, t.topic, data)
+}
+
+// Subscribe 订阅主题，返回取消订阅函数
+func (t *TypedTopic[T]) Subscribe(fn func(ctx context.Context, data T), opts ...SubscribeOption) func() {
+	return t.SubscribeE(func(ctx context.Context, data T) error {
+		fn(ctx, data)
+		return nil
+	}, opts...)
+}
+
+// SubscribeE 同 Subscribe，订阅函数返回错误时按 WithRetry 重试，仍失败的事件转入死信
+func (t *TypedTopic[T]) SubscribeE(fn func(ctx context.Context, data T) error, opts ...SubscribeOption) func() {
+	return t.bus.SubscribeE(t.topic, func(e *Event) error {
+		data, ok := e.Data.(T)
+		if !ok {
+			// 通过非泛型接口发布了错误类型的数据
+			logger.Errorf("eventbus: topic=%s unexpected data type %T", t.topic, e.Data)
+			return nil
+		}
+		return fn(e.Context(), data)
+	}, opts...)
+}
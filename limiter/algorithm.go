@@ -0,0 +1,350 @@
+package limiter
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/lance4117/gofuse/logger"
+	"golang.org/x/time/rate"
+)
+
+// Algorithm 限流算法
+type Algorithm string
+
+const (
+	TokenBucket   Algorithm = "token_bucket"   // 令牌桶，默认；Rate 为每秒补充速率，Burst 为峰值容量
+	SlidingLog    Algorithm = "sliding_log"    // 滑动窗口日志，任意 Window 时长内最多 Burst 次，精确但每个 key 占用 O(Burst) 内存
+	SlidingWindow Algorithm = "sliding_window" // 滑动窗口计数，按上一窗口计数加权估算，内存 O(1)
+	FixedWindow   Algorithm = "fixed_window"   // 固定窗口计数，每个 Window 最多 Burst 次，窗口边界处可能出现两倍突发
+	Concurrency   Algorithm = "concurrency"    // 并发数限制，同时处理中的请求最多 Burst 个，放行后需归还
+)
+
+// Limiter 单个 key 的限流器
+type Limiter interface {
+	// Allow 尝试放行一次
+	Allow() bool
+	// Take 尝试放行一次，并返回剩余配额等信息
+	Take() Result
+	// Wait 阻塞等待放行，支持 context
+	Wait(ctx context.Context) error
+	// Release 归还一次配额，仅对并发限流器有效，其余算法为空操作
+	Release()
+}
+
+// reconfigurable 可在运行时调整配置的限流器，所有内置算法均实现
+type reconfigurable interface {
+	setConfig(cfg Config)
+}
+
+// newLimiter 按配置的算法创建限流器
+func newLimiter(cfg Config) Limiter {
+	switch cfg.Algorithm {
+	case "", TokenBucket:
+		return &tokenBucket{lim: rate.NewLimiter(rate.Limit(cfg.Rate), cfg.Burst)}
+	case SlidingLog:
+		return &slidingLog{limit: cfg.Burst, window: cfg.Window}
+	case SlidingWindow:
+		return &slidingWindow{limit: cfg.Burst, window: cfg.Window}
+	case FixedWindow:
+		return &fixedWindow{limit: cfg.Burst, window: cfg.Window}
+	case Concurrency:
+		return newConcurrency(cfg.Burst)
+	default:
+		logger.Warnf("limiter: unknown algorithm %q, use %s", cfg.Algorithm, TokenBucket)
+		return &tokenBucket{lim: rate.NewLimiter(rate.Limit(cfg.Rate), cfg.Burst)}
+	}
+}
+
+// waitFor 重复尝试放行，被拒绝时按 RetryAfter 等待
+func waitFor(ctx context.Context, take func() Result) error {
+	for {
+		res := take()
+		if res.Allowed {
+			return nil
+		}
+		timer := time.NewTimer(max(res.RetryAfter, time.Millisecond))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// tokenBucket 基于 rate.Limiter 的令牌桶
+type tokenBucket struct {
+	lim *rate.Limiter
+}
+
+func (l *tokenBucket) Allow() bool {
+	return l.lim.Allow()
+}
+
+func (l *tokenBucket) Take() Result {
+	now := time.Now()
+	res := Result{Limit: l.lim.Burst()}
+	r := l.lim.ReserveN(now, 1)
+	if !r.OK() {
+		return res
+	}
+	if delay := r.DelayFrom(now); delay > 0 {
+		// 令牌不足，撤销预约
+		r.CancelAt(now)
+		res.RetryAfter = delay
+	} else {
+		res.Allowed = true
+	}
+	tokens := l.lim.TokensAt(now)
+	res.Remaining = max(int(math.Floor(tokens)), 0)
+	if limit := float64(l.lim.Limit()); limit > 0 {
+		res.ResetAfter = time.Duration((float64(res.Limit) - tokens) / limit * float64(time.Second))
+	}
+	return res
+}
+
+func (l *tokenBucket) Wait(ctx context.Context) error {
+	return l.lim.Wait(ctx)
+}
+
+func (l *tokenBucket) Release() {}
+
+func (l *tokenBucket) setConfig(cfg Config) {
+	l.lim.SetLimit(rate.Limit(cfg.Rate))
+	l.lim.SetBurst(cfg.Burst)
+}
+
+// slidingLog 滑动窗口日志，记录窗口内每次放行的时间
+type slidingLog struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	log    []time.Time // 按时间升序
+}
+
+func (l *slidingLog) Allow() bool {
+	return l.Take().Allowed
+}
+
+func (l *slidingLog) Take() Result {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// 清理窗口外的记录
+	i := 0
+	for i < len(l.log) && now.Sub(l.log[i]) >= l.window {
+		i++
+	}
+	l.log = l.log[i:]
+
+	res := Result{Limit: l.limit}
+	if len(l.log) < l.limit {
+		l.log = append(l.log, now)
+		res.Allowed = true
+	} else {
+		res.RetryAfter = l.log[0].Add(l.window).Sub(now)
+	}
+	res.Remaining = l.limit - len(l.log)
+	if n := len(l.log); n > 0 {
+		res.ResetAfter = l.log[n-1].Add(l.window).Sub(now)
+	}
+	return res
+}
+
+func (l *slidingLog) Wait(ctx context.Context) error {
+	return waitFor(ctx, l.Take)
+}
+
+func (l *slidingLog) Release() {}
+
+func (l *slidingLog) setConfig(cfg Config) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit, l.window = cfg.Burst, cfg.Window
+}
+
+// slidingWindow 滑动窗口计数，估算值 = 上一窗口计数 × 上一窗口与滑动窗口的重叠比例 + 当前窗口计数
+type slidingWindow struct {
+	mu        sync.Mutex
+	limit     int
+	window    time.Duration
+	start     time.Time // 当前窗口起点
+	prev, cur int
+}
+
+func (l *slidingWindow) Allow() bool {
+	return l.Take().Allowed
+}
+
+func (l *slidingWindow) Take() Result {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	start := now.Truncate(l.window)
+	switch elapsed := start.Sub(l.start); {
+	case elapsed >= 2*l.window:
+		l.prev, l.cur = 0, 0
+	case elapsed >= l.window:
+		l.prev, l.cur = l.cur, 0
+	}
+	l.start = start
+
+	res := Result{Limit: l.limit}
+	weight := 1 - float64(now.Sub(start))/float64(l.window)
+	estimate := float64(l.prev)*weight + float64(l.cur)
+	if estimate+1 <= float64(l.limit) {
+		l.cur++
+		estimate++
+		res.Allowed = true
+	} else if l.cur+1 <= l.limit && l.prev > 0 {
+		// 等待上一窗口的权重衰减到足以放行
+		need := (estimate + 1 - float64(l.limit)) / float64(l.prev)
+		res.RetryAfter = time.Duration(need * float64(l.window))
+	} else {
+		res.RetryAfter = start.Add(l.window).Sub(now)
+	}
+	res.Remaining = max(int(float64(l.limit)-estimate), 0)
+	res.ResetAfter = start.Add(2 * l.window).Sub(now)
+	return res
+}
+
+func (l *slidingWindow) Wait(ctx context.Context) error {
+	return waitFor(ctx, l.Take)
+}
+
+func (l *slidingWindow) Release() {}
+
+func (l *slidingWindow) setConfig(cfg Config) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if cfg.Window != l.window {
+		// 窗口对齐方式改变，重新计数
+		l.start, l.prev, l.cur = time.Time{}, 0, 0
+	}
+	l.limit, l.window = cfg.Burst, cfg.Window
+}
+
+// fixedWindow 固定窗口计数，窗口按 Window 对齐
+type fixedWindow struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	start  time.Time
+	count  int
+}
+
+func (l *fixedWindow) Allow() bool {
+	return l.Take().Allowed
+}
+
+func (l *fixedWindow) Take() Result {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if start := now.Truncate(l.window); !start.Equal(l.start) {
+		l.start, l.count = start, 0
+	}
+	res := Result{Limit: l.limit, ResetAfter: l.start.Add(l.window).Sub(now)}
+	if l.count < l.limit {
+		l.count++
+		res.Allowed = true
+	} else {
+		res.RetryAfter = res.ResetAfter
+	}
+	res.Remaining = l.limit - l.count
+	return res
+}
+
+func (l *fixedWindow) Wait(ctx context.Context) error {
+	return waitFor(ctx, l.Take)
+}
+
+func (l *fixedWindow) Release() {}
+
+func (l *fixedWindow) setConfig(cfg Config) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if cfg.Window != l.window {
+		l.start, l.count = time.Time{}, 0
+	}
+	l.limit, l.window = cfg.Burst, cfg.Window
+}
+
+// concurrency 并发数限制
+type concurrency struct {
+	mu       sync.Mutex
+	limit    int
+	inflight int
+	released chan struct{} // 有配额归还时关闭并替换，唤醒等待者
+}
+
+func newConcurrency(limit int) *concurrency {
+	return &concurrency{limit: limit, released: make(chan struct{})}
+}
+
+func (l *concurrency) Allow() bool {
+	return l.Take().Allowed
+}
+
+// Take 放行后需调用 Result.Release 或 Release 归还
+func (l *concurrency) Take() Result {
+	res, _ := l.take()
+	return res
+}
+
+func (l *concurrency) take() (Result, <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	res := Result{Limit: l.limit}
+	if l.inflight < l.limit {
+		l.inflight++
+		res.Allowed = true
+		res.release = sync.OnceFunc(l.Release)
+	}
+	res.Remaining = l.limit - l.inflight
+	return res, l.released
+}
+
+func (l *concurrency) Wait(ctx context.Context) error {
+	for {
+		res, released := l.take()
+		if res.Allowed {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-released:
+		}
+	}
+}
+
+func (l *concurrency) setConfig(cfg Config) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = cfg.Burst
+	// 上限可能调大，唤醒等待者重新尝试
+	close(l.released)
+	l.released = make(chan struct{})
+}
+
+func (l *concurrency) busy() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inflight > 0
+}
+
+func (l *concurrency) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inflight > 0 {
+		l.inflight--
+	}
+	close(l.released)
+	l.released = make(chan struct{})
+}
@@ -0,0 +1,86 @@
+package limiter
+
+import (
+	"container/list"
+	"sync/atomic"
+	"time"
+)
+
+// entry Manager 中跟踪的单个 key
+type entry struct {
+	key      string
+	rule     string    // 配置表中的 key
+	algo     Algorithm // 创建 lim 时的算法
+	lim      Limiter
+	lastUsed atomic.Int64 // 最近一次使用的时间，UnixNano
+	elem     *list.Element
+}
+
+// busy 仍有请求占用配额的 limiter 不会被淘汰，如并发限流中未归还的请求
+type busy interface {
+	busy() bool
+}
+
+func (e *entry) busy() bool {
+	b, ok := e.lim.(busy)
+	return ok && b.busy()
+}
+
+// remove 移除 key，调用方需持有写锁
+func (m *Manager) remove(e *entry) {
+	delete(m.limiters, e.key)
+	if m.lru != nil && e.elem != nil {
+		m.lru.Remove(e.elem)
+	}
+}
+
+// evictOverflow 超出 MaxKeys 时从最久未使用的 key 开始淘汰，不淘汰表头刚使用的 key，调用方需持有写锁
+func (m *Manager) evictOverflow() {
+	for elem := m.lru.Back(); elem != m.lru.Front() && len(m.limiters) > m.opts.MaxKeys; {
+		e := elem.Value.(*entry)
+		elem = elem.Prev()
+		if !e.busy() {
+			m.remove(e)
+		}
+	}
+}
+
+// evictIdle 清理闲置超过 IdleTTL 的 key
+func (m *Manager) evictIdle(now time.Time) {
+	deadline := now.Add(-m.opts.IdleTTL).UnixNano()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.limiters {
+		if e.lastUsed.Load() < deadline && !e.busy() {
+			m.remove(e)
+		}
+	}
+}
+
+// janitor 后台定期清理闲置的 key
+func (m *Manager) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case now := <-ticker.C:
+			m.evictIdle(now)
+		}
+	}
+}
+
+// Len 当前跟踪的 key 数量
+func (m *Manager) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.limiters)
+}
+
+// Stop 结束后台清理，可重复调用；已跟踪的 key 仍可继续使用
+func (m *Manager) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+	})
+}
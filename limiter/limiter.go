@@ -1,60 +1,164 @@
+// Package limiter 按 key 区分的限流。
+//
+// Manager 支持令牌桶、滑动窗口日志、滑动窗口计数、固定窗口与并发数限流，
+// 配置可通过 UpdateConfig 或 WatchConfig 从 config 热更新，可按闲置时长与 key 数量上限（LRU）清理；
+// RedisManager 基于 Redis 让多个副本共享配额，Redis 不可用时退化为本地限流。
+// Middleware 可按路径、IP、用户、应用、请求头或组合 key 区分客户端，并为不同等级的应用配置不同配额，
+// 响应携带 RateLimit-* 与 Retry-After 头；UnaryServerInterceptor、StreamServerInterceptor 为 gRPC 版本。
 package limiter
 
 import (
+	"container/list"
 	"context"
-	"net/http"
 	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/lance4117/gofuse/logger"
-	"github.com/lance4117/gofuse/server"
 	"golang.org/x/time/rate"
 )
 
 // Config 速率配置
 type Config struct {
-	Rate  float64 `yaml:"rate" json:"rate"`   // 每秒允许的请求数
-	Burst int     `yaml:"burst" json:"burst"` // 峰值令牌桶容量
+	Algorithm Algorithm     `yaml:"algorithm" json:"algorithm"` // 限流算法，默认令牌桶
+	Rate      float64       `yaml:"rate" json:"rate"`           // 每秒允许的请求数，仅令牌桶使用
+	Burst     int           `yaml:"burst" json:"burst"`         // 峰值令牌桶容量；窗口算法为每个窗口的请求数，并发限流为最大并发数
+	Window    time.Duration `yaml:"window" json:"window"`       // 窗口时长，仅窗口算法使用，默认 1s
+}
+
+// Result 一次限流判定的结果
+type Result struct {
+	Allowed    bool          // 是否放行
+	Limit      int           // 配额上限（峰值容量）
+	Remaining  int           // 剩余配额
+	RetryAfter time.Duration // 被拒绝时建议的重试等待时间
+	ResetAfter time.Duration // 配额完全恢复所需的时间
+
+	release func() // 并发限流放行后归还配额
+}
+
+// Release 归还本次放行占用的配额，仅对并发限流有效，可重复调用
+func (r Result) Release() {
+	if r.release != nil {
+		r.release()
+	}
+}
+
+// KeyLimiter 按 key 限流，Manager 与 RedisManager 均实现
+type KeyLimiter interface {
+	// Allow 尝试放行一次
+	Allow(key string) bool
+	// Take 尝试放行一次，并返回剩余配额等信息
+	Take(key string) Result
+	// TakeRule 使用配置表中 rule 对应的配置对 key 限流，用于多个 key 共用一条配置，如按用户区分的同一路由
+	TakeRule(rule, key string) Result
+	// Wait 阻塞等待放行，支持 context
+	Wait(ctx context.Context, key string) error
+}
+
+var (
+	_ KeyLimiter = (*Manager)(nil)
+	_ KeyLimiter = (*RedisManager)(nil)
+)
+
+// ManagerOptions Manager 的内存控制配置，用于按 IP 等高基数 key 限流的场景
+type ManagerOptions struct {
+	IdleTTL       time.Duration // 闲置超过该时长的 key 由后台清理，0 表示不清理
+	MaxKeys       int           // 最多跟踪的 key 数量，超出时淘汰最久未使用的 key，0 表示不限
+	CleanInterval time.Duration // 后台清理的间隔，默认为 IdleTTL 的一半
 }
 
 // Manager 管理一组 limiter，支持按 key 区分，如 API 路径 / 用户ID 等
 type Manager struct {
 	mu       sync.RWMutex
-	limiters map[string]*rate.Limiter
-	config   map[string]Config // 配置表，可动态更新
+	limiters map[string]*entry
+	config   atomic.Pointer[map[string]Config] // 配置表，可通过 UpdateConfig 动态更新
+	opts     ManagerOptions
+	lru      *list.List // 设置 MaxKeys 时按访问顺序排列，表头为最近使用
+
+	stop     chan struct{}
+	stopOnce sync.Once
 }
 
 // NewLimiterManager 初始化
 func NewLimiterManager(config map[string]Config) *Manager {
-	return &Manager{
-		limiters: make(map[string]*rate.Limiter),
-		config:   config,
+	return NewLimiterManagerWithOptions(config, ManagerOptions{})
+}
+
+// NewLimiterManagerWithOptions 初始化，并按 opts 限制跟踪的 key；设置 IdleTTL 时需调用 Stop 结束后台清理
+func NewLimiterManagerWithOptions(config map[string]Config, opts ManagerOptions) *Manager {
+	m := &Manager{
+		limiters: make(map[string]*entry),
+		opts:     opts,
+		stop:     make(chan struct{}),
+	}
+	m.config.Store(&config)
+	if opts.MaxKeys > 0 {
+		m.lru = list.New()
 	}
+	if opts.IdleTTL > 0 {
+		if opts.CleanInterval <= 0 {
+			m.opts.CleanInterval = max(opts.IdleTTL/2, time.Millisecond)
+		}
+		go m.janitor(m.opts.CleanInterval)
+	}
+	return m
 }
 
-// GetLimiter 获取对应 key 的 limiter，不存在则创建
+// GetLimiter 获取对应 key 的令牌桶，不存在则创建；配置的算法不是令牌桶时返回 nil，请使用 Get
 func (m *Manager) GetLimiter(key string) *rate.Limiter {
-	m.mu.RLock()
-	lim, ok := m.limiters[key]
-	m.mu.RUnlock()
-	if ok {
-		return lim
+	if tb, ok := m.Get(key).(*tokenBucket); ok {
+		return tb.lim
+	}
+	return nil
+}
+
+// Get 获取对应 key 的限流器，不存在则按配置的算法创建
+func (m *Manager) Get(key string) Limiter {
+	return m.getLimiter(key, key)
+}
+
+// getLimiter 获取对应 key 的 limiter，不存在则按 rule 的配置创建
+func (m *Manager) getLimiter(rule, key string) Limiter {
+	now := time.Now().UnixNano()
+	// 未设置 MaxKeys 时无需维护访问顺序，读锁即可
+	if m.lru == nil {
+		m.mu.RLock()
+		e, ok := m.limiters[key]
+		var lim Limiter
+		if ok {
+			lim = e.lim
+		}
+		m.mu.RUnlock()
+		if ok {
+			e.lastUsed.Store(now)
+			return lim
+		}
 	}
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	// double check
-	if lim, ok := m.limiters[key]; ok {
-		return lim
+	if e, ok := m.limiters[key]; ok {
+		e.lastUsed.Store(now)
+		if m.lru != nil {
+			m.lru.MoveToFront(e.elem)
+		}
+		return e.lim
 	}
 
-	cfg := m.effectiveConfig(key)
-	lim = rate.NewLimiter(rate.Limit(cfg.Rate), cfg.Burst)
-	m.limiters[key] = lim
-	return lim
+	cfg := m.effectiveConfig(rule)
+	e := &entry{key: key, rule: rule, algo: cfg.Algorithm, lim: newLimiter(cfg)}
+	e.lastUsed.Store(now)
+	m.limiters[key] = e
+	if m.lru != nil {
+		e.elem = m.lru.PushFront(e)
+		m.evictOverflow()
+	}
+	return e.lim
 }
 
 func (m *Manager) effectiveConfig(key string) Config {
-	cfg, ok := m.config[key]
+	cfg, ok := (*m.config.Load())[key]
 	if !ok {
 		cfg = Config{Rate: 5, Burst: 5} // 全局兜底，避免零值导致拒绝所有请求
 	}
@@ -64,29 +168,52 @@ func (m *Manager) effectiveConfig(key string) Config {
 	if cfg.Burst <= 0 {
 		cfg.Burst = 5
 	}
+	if cfg.Window <= 0 {
+		cfg.Window = time.Second
+	}
+	if cfg.Algorithm == "" {
+		cfg.Algorithm = TokenBucket
+	}
 	return cfg
 }
 
+// UpdateConfig 替换配置表，已创建的 limiter 立即按新配置调整速率与容量；
+// 算法发生变化的 limiter 会重新创建，已有计数清零
+func (m *Manager) UpdateConfig(config map[string]Config) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.config.Store(&config)
+	for _, e := range m.limiters {
+		cfg := m.effectiveConfig(e.rule)
+		if cfg.Algorithm != e.algo {
+			e.lim, e.algo = newLimiter(cfg), cfg.Algorithm
+			continue
+		}
+		e.lim.(reconfigurable).setConfig(cfg)
+	}
+}
+
 // Allow 尝试放行一次
 func (m *Manager) Allow(key string) bool {
-	return m.GetLimiter(key).Allow()
+	return m.Get(key).Allow()
 }
 
-// Wait 阻塞等待令牌，支持 context
-func (m *Manager) Wait(ctx context.Context, key string) error {
-	return m.GetLimiter(key).Wait(ctx)
+// Take 尝试放行一次，并返回剩余配额等信息；并发限流放行后需调用 Result.Release
+func (m *Manager) Take(key string) Result {
+	return m.Get(key).Take()
+}
+
+// TakeRule 使用 rule 对应的配置对 key 限流，key 首次出现时确定配置
+func (m *Manager) TakeRule(rule, key string) Result {
+	return m.getLimiter(rule, key).Take()
 }
 
-// Middleware 基于 API Path 的限流中间件
-func Middleware(m *Manager) server.ContextHandler {
-	return func(c *server.Context) {
-		key := c.Path() // 使用路由路径作为 key
+// Wait 阻塞等待放行，支持 context；并发限流放行后需调用 Release
+func (m *Manager) Wait(ctx context.Context, key string) error {
+	return m.Get(key).Wait(ctx)
+}
 
-		if !m.Allow(key) {
-			logger.Errorf("%s has too many requests", key)
-			c.Fail(http.StatusTooManyRequests)
-			return
-		}
-		c.Next()
-	}
+// Release 归还 key 对应的一次配额，仅对并发限流有效
+func (m *Manager) Release(key string) {
+	m.Get(key).Release()
 }
@@ -1,10 +1,27 @@
 package limiter
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lance4117/gofuse/config"
+	"github.com/lance4117/gofuse/gen"
 	"github.com/lance4117/gofuse/server"
+	"github.com/lance4117/gofuse/store/kvs"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 func TestLimiter(t *testing.T) {
@@ -24,3 +41,351 @@ func TestLimiter(t *testing.T) {
 
 	_ = s.Run(":8080")
 }
+
+func TestManagerGetLimiter(t *testing.T) {
+	lm := NewLimiterManager(map[string]Config{"/tb": {Rate: 1, Burst: 2}, "/fw": {Algorithm: FixedWindow, Burst: 2}})
+	if lim := lm.GetLimiter("/tb"); lim == nil || lim.Burst() != 2 {
+		t.Fatalf("expected token bucket with burst 2, got %v", lim)
+	}
+	if lm.GetLimiter("/fw") != nil {
+		t.Fatal("expected nil rate.Limiter for fixed window")
+	}
+	if _, ok := lm.Get("/fw").(*fixedWindow); !ok {
+		t.Fatalf("expected fixed window limiter, got %T", lm.Get("/fw"))
+	}
+}
+
+func TestManagerTake(t *testing.T) {
+	lm := NewLimiterManager(map[string]Config{"/take": {Rate: 1, Burst: 2}})
+
+	for i, remaining := range []int{1, 0} {
+		res := lm.Take("/take")
+		if !res.Allowed || res.Remaining != remaining || res.Limit != 2 {
+			t.Fatalf("take %d: unexpected result %+v", i, res)
+		}
+	}
+	res := lm.Take("/take")
+	if res.Allowed || res.RetryAfter <= 0 || res.RetryAfter > time.Second {
+		t.Fatalf("expected rejection with retry after, got %+v", res)
+	}
+}
+
+func TestWindowAlgorithms(t *testing.T) {
+	for _, algo := range []Algorithm{SlidingLog, SlidingWindow, FixedWindow} {
+		t.Run(string(algo), func(t *testing.T) {
+			lm := NewLimiterManager(map[string]Config{"/w": {Algorithm: algo, Burst: 3, Window: 200 * time.Millisecond}})
+			allowed := 0
+			for range 5 {
+				if lm.Allow("/w") {
+					allowed++
+				}
+			}
+			if allowed > 3 || allowed == 0 {
+				t.Fatalf("expected at most 3 allowed, got %d", allowed)
+			}
+			res := lm.Take("/w")
+			if res.Allowed || res.Limit != 3 || res.RetryAfter <= 0 || res.RetryAfter > 400*time.Millisecond {
+				t.Fatalf("expected rejection with retry after, got %+v", res)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if err := lm.Wait(ctx, "/w"); err != nil {
+				t.Fatalf("wait: %v", err)
+			}
+		})
+	}
+}
+
+func TestConcurrencyLimiter(t *testing.T) {
+	lm := NewLimiterManager(map[string]Config{"/c": {Algorithm: Concurrency, Burst: 2}})
+
+	r1, r2 := lm.Take("/c"), lm.Take("/c")
+	if !r1.Allowed || !r2.Allowed || r2.Remaining != 0 {
+		t.Fatalf("unexpected results %+v %+v", r1, r2)
+	}
+	if lm.Allow("/c") {
+		t.Fatal("expected rejection when in-flight limit reached")
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		done <- lm.Wait(ctx, "/c")
+	}()
+	time.Sleep(20 * time.Millisecond)
+	r1.Release()
+	r1.Release() // 重复归还无效
+	if err := <-done; err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	if res := lm.Take("/c"); res.Allowed {
+		t.Fatalf("expected rejection after duplicate release, got %+v", res)
+	}
+}
+
+func TestMiddlewareKey(t *testing.T) {
+	cfg := map[string]Config{
+		"/order:free": {Rate: 0.1, Burst: 1},
+		"/order:paid": {Rate: 0.1, Burst: 3},
+	}
+	lm := NewLimiterManager(cfg)
+
+	s := server.NewHTTP(false)
+	s.Use(func(c *server.Context) {
+		uid, _ := strconv.ParseInt(c.Header().Get("Uid"), 10, 64)
+		appId, _ := strconv.ParseInt(c.Header().Get("AppId"), 10, 64)
+		c.SetAccount(&server.Account{Uid: uid, AppId: appId})
+	})
+	s.Use(MiddlewareWithOptions(lm, MiddlewareOptions{
+		Rule: Compose(ByPath(), ByAppIdTier(map[int64]string{2: "paid"}, "free")),
+		Key:  ByUid(),
+	}))
+	s.GET("/order", func(ctx *server.Context) {
+		ctx.OK(nil)
+	})
+
+	do := func(uid, appId string) int {
+		req := httptest.NewRequest(http.MethodGet, "/order", nil)
+		req.Header.Set("Uid", uid)
+		req.Header.Set("AppId", appId)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// 免费应用每个用户 1 次，互不影响
+	for _, uid := range []string{"1", "2"} {
+		if code := do(uid, "1"); code != http.StatusOK {
+			t.Fatalf("uid %s: expected 200, got %d", uid, code)
+		}
+		if code := do(uid, "1"); code != http.StatusTooManyRequests {
+			t.Fatalf("uid %s: expected 429, got %d", uid, code)
+		}
+	}
+	// 付费应用每个用户 3 次
+	for i := range 3 {
+		if code := do("1", "2"); code != http.StatusOK {
+			t.Fatalf("paid request %d: expected 200, got %d", i, code)
+		}
+	}
+	if code := do("1", "2"); code != http.StatusTooManyRequests {
+		t.Fatalf("paid: expected 429, got %d", code)
+	}
+}
+
+func TestMiddlewareHeaders(t *testing.T) {
+	lm := NewLimiterManager(map[string]Config{"/h": {Rate: 0.5, Burst: 2}})
+	s := server.NewHTTP(false)
+	s.Use(Middleware(lm))
+	s.GET("/h", func(ctx *server.Context) {
+		ctx.OK(nil)
+	})
+
+	do := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/h", nil))
+		return w
+	}
+	w := do()
+	if w.Header().Get("RateLimit-Limit") != "2" || w.Header().Get("RateLimit-Remaining") != "1" ||
+		w.Header().Get("RateLimit-Reset") != "2" || w.Header().Get("Retry-After") != "" {
+		t.Fatalf("unexpected headers on allowed request: %v", w.Header())
+	}
+	do()
+	w = do()
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("RateLimit-Remaining") != "0" ||
+		w.Header().Get("Retry-After") != "2" {
+		t.Fatalf("unexpected rejection %d %v", w.Code, w.Header())
+	}
+}
+
+func TestGrpcInterceptor(t *testing.T) {
+	lm := NewLimiterManager(map[string]Config{"/pkg.Svc/Call": {Rate: 0.1, Burst: 1}})
+	intercept := UnaryServerInterceptor(lm, GrpcOptions{Key: ByMetadata("uid")})
+	info := &grpc.UnaryServerInfo{FullMethod: "/pkg.Svc/Call"}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return "ok", nil
+	}
+
+	call := func(uid string) error {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("uid", uid))
+		_, err := intercept(ctx, nil, info, handler)
+		return err
+	}
+	if err := call("1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := call("1"); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", err)
+	}
+	if err := call("2"); err != nil {
+		t.Fatalf("expected separate quota for uid 2, got %v", err)
+	}
+}
+
+func TestManagerEviction(t *testing.T) {
+	lm := NewLimiterManagerWithOptions(nil, ManagerOptions{MaxKeys: 2})
+	defer lm.Stop()
+	lm.Allow("a")
+	lm.Allow("b")
+	lm.Allow("a")
+	lm.Allow("c") // 淘汰最久未使用的 b
+	if lm.Len() != 2 {
+		t.Fatalf("expected 2 keys, got %d", lm.Len())
+	}
+	lm.mu.RLock()
+	_, hasA := lm.limiters["a"]
+	_, hasB := lm.limiters["b"]
+	lm.mu.RUnlock()
+	if !hasA || hasB {
+		t.Fatalf("expected b evicted, has a=%v b=%v", hasA, hasB)
+	}
+
+	idle := NewLimiterManagerWithOptions(map[string]Config{"busy": {Algorithm: Concurrency, Burst: 1}},
+		ManagerOptions{IdleTTL: 50 * time.Millisecond, CleanInterval: 10 * time.Millisecond})
+	defer idle.Stop()
+	idle.Allow("x")
+	res := idle.Take("busy")
+	time.Sleep(150 * time.Millisecond)
+	if idle.Len() != 1 {
+		t.Fatalf("expected only in-flight key kept, got %d", idle.Len())
+	}
+	res.Release()
+	time.Sleep(150 * time.Millisecond)
+	if idle.Len() != 0 {
+		t.Fatalf("expected all idle keys evicted, got %d", idle.Len())
+	}
+}
+
+func TestUpdateConfig(t *testing.T) {
+	lm := NewLimiterManager(map[string]Config{
+		"/tb": {Rate: 0.1, Burst: 1},
+		"/fw": {Algorithm: FixedWindow, Burst: 1, Window: time.Hour},
+	})
+	for _, key := range []string{"/tb", "/fw"} {
+		lm.Allow(key)
+		if lm.Allow(key) {
+			t.Fatalf("%s: expected rejection before update", key)
+		}
+	}
+
+	lm.UpdateConfig(map[string]Config{
+		"/tb": {Rate: 0.1, Burst: 3},
+		"/fw": {Algorithm: FixedWindow, Burst: 3, Window: time.Hour},
+	})
+	// 令牌桶调大容量不会立即补充令牌
+	if res := lm.Take("/tb"); res.Limit != 3 {
+		t.Fatalf("/tb: expected new burst, got %+v", res)
+	}
+	if res := lm.Take("/fw"); !res.Allowed || res.Limit != 3 {
+		t.Fatalf("/fw: expected allowed with new limit, got %+v", res)
+	}
+
+	// 切换算法后重新创建
+	lm.UpdateConfig(map[string]Config{"/tb": {Algorithm: Concurrency, Burst: 1}})
+	if res := lm.Take("/tb"); !res.Allowed || lm.Allow("/tb") {
+		t.Fatalf("expected concurrency limiter after update, got %+v", res)
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	if config.Initialized {
+		t.Skip("config already initialized")
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(burst int) {
+		data := fmt.Sprintf("limiter:\n  routes:\n    /watch:\n      rate: 0.1\n      burst: %d\n", burst)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(1)
+	if err := config.Init(path); err != nil {
+		t.Fatal(err)
+	}
+
+	lm := NewLimiterManager(nil)
+	if err := WatchConfig(lm, "limiter.routes"); err != nil {
+		t.Fatal(err)
+	}
+	if res := lm.Take("/watch"); res.Limit != 1 {
+		t.Fatalf("expected burst 1 from config, got %+v", res)
+	}
+
+	write(4)
+	deadline := time.Now().Add(3 * time.Second)
+	for lm.Take("/watch").Limit != 4 {
+		// 重载期间读取配置不应产生数据竞争
+		_ = config.All()
+		if time.Now().After(deadline) {
+			t.Fatal("config change not applied")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestRedisManager(t *testing.T) {
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("set TEST_REDIS_ADDR to run redis integration tests")
+	}
+	cfg := kvs.NewRedisConfig(addr, os.Getenv("TEST_REDIS_PASSWORD"), 0, 10)
+	key := "/redis/" + gen.ShortID()
+	config := map[string]Config{key: {Rate: 10, Burst: 3}}
+	opts := RedisOptions{Prefix: "limiter:test:"}
+	a := NewRedisManager(cfg, config, opts)
+	defer a.Close()
+	b := NewRedisManager(cfg, config, opts)
+	defer b.Close()
+
+	// 两个实例共享 burst=3 的配额
+	allowed := 0
+	for i := 0; i < 3; i++ {
+		if a.Allow(key) {
+			allowed++
+		}
+		if b.Allow(key) {
+			allowed++
+		}
+	}
+	if a.downUntil.Load() > 0 {
+		t.Skip("redis not available")
+	}
+	if allowed != 3 {
+		t.Errorf("expected 3 allowed across instances, got %d", allowed)
+	}
+	res := a.Take(key)
+	if res.Allowed || res.RetryAfter <= 0 || res.Limit != 3 {
+		t.Errorf("expected rejection with retry after, got %+v", res)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := b.Wait(ctx, key); err != nil {
+		t.Errorf("wait: %v", err)
+	}
+}
+
+func TestRedisFallback(t *testing.T) {
+	// 不可达的 Redis 退化为本地限流
+	cfg := kvs.NewRedisConfig("127.0.0.1:1", "", 0, 1)
+	m := NewRedisManager(cfg, map[string]Config{"/fallback": {Rate: 1, Burst: 2}}, RedisOptions{})
+	defer m.Close()
+
+	if !m.Allow("/fallback") || !m.Allow("/fallback") {
+		t.Fatal("expected local limiter to allow burst")
+	}
+	if m.Allow("/fallback") {
+		t.Fatal("expected local limiter to reject after burst")
+	}
+
+	// 传入的客户端由调用方管理，Close 后仍可使用
+	cli := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1"})
+	defer cli.Close()
+	_ = NewRedisManagerWithClient(cli, nil, RedisOptions{}).Close()
+	if err := cli.Ping(context.Background()).Err(); errors.Is(err, redis.ErrClosed) {
+		t.Fatal("shared redis client should not be closed")
+	}
+}
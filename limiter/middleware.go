@@ -0,0 +1,140 @@
+package limiter
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lance4117/gofuse/logger"
+	"github.com/lance4117/gofuse/server"
+)
+
+// KeyFunc 从请求中提取限流 key
+type KeyFunc func(c *server.Context) string
+
+// MiddlewareOptions 限流中间件配置
+type MiddlewareOptions struct {
+	// Rule 选择配置表中的配置，默认 ByPath，即按路由配置；可配合 ByAppIdTier 为不同等级的客户端使用不同配额
+	Rule KeyFunc
+	// Key 区分客户端，为空时同一 Rule 下的请求共享配额；设置后每个客户端单独计数，如 ByIP、ByUid
+	Key KeyFunc
+}
+
+// ByPath 按路由路径
+func ByPath() KeyFunc {
+	return func(c *server.Context) string {
+		return c.Path()
+	}
+}
+
+// ByIP 按客户端 IP
+func ByIP() KeyFunc {
+	return func(c *server.Context) string {
+		return c.IP()
+	}
+}
+
+// ByUid 按用户ID，未登录时为 "0"
+func ByUid() KeyFunc {
+	return func(c *server.Context) string {
+		return strconv.FormatInt(c.Account().Uid, 10)
+	}
+}
+
+// ByAppId 按应用ID
+func ByAppId() KeyFunc {
+	return func(c *server.Context) string {
+		return strconv.FormatInt(c.Account().AppId, 10)
+	}
+}
+
+// ByHeader 按请求头的值
+func ByHeader(name string) KeyFunc {
+	return func(c *server.Context) string {
+		return c.Header().Get(name)
+	}
+}
+
+// Const 固定值，如作为 Rule 时所有路由共用一条配置
+func Const(key string) KeyFunc {
+	return func(*server.Context) string {
+		return key
+	}
+}
+
+// ByAppIdTier 按应用ID所属的等级，tiers 为应用ID到等级名称的映射，未配置的应用使用 fallback
+func ByAppIdTier(tiers map[int64]string, fallback string) KeyFunc {
+	return func(c *server.Context) string {
+		if tier, ok := tiers[c.Account().AppId]; ok {
+			return tier
+		}
+		return fallback
+	}
+}
+
+// Compose 组合多个 KeyFunc，以 ":" 连接，如 Compose(ByPath(), ByUid()) 得到 "/api/order:10086"
+func Compose(fns ...KeyFunc) KeyFunc {
+	return func(c *server.Context) string {
+		parts := make([]string, len(fns))
+		for i, fn := range fns {
+			parts[i] = fn(c)
+		}
+		return strings.Join(parts, ":")
+	}
+}
+
+// Middleware 基于 API Path 的限流中间件
+func Middleware(m KeyLimiter) server.ContextHandler {
+	return MiddlewareWithOptions(m, MiddlewareOptions{})
+}
+
+// setHeaders 写入 RateLimit-Limit / RateLimit-Remaining / RateLimit-Reset，被拒绝时写入 Retry-After，单位为秒
+func setHeaders(h http.Header, res Result) {
+	for k, v := range headerValues(res) {
+		h.Set(k, v)
+	}
+}
+
+// headerValues 限流结果对应的响应头
+func headerValues(res Result) map[string]string {
+	values := map[string]string{
+		"RateLimit-Limit":     strconv.Itoa(res.Limit),
+		"RateLimit-Remaining": strconv.Itoa(res.Remaining),
+		"RateLimit-Reset":     strconv.Itoa(ceilSeconds(res.ResetAfter)),
+	}
+	if !res.Allowed {
+		values["Retry-After"] = strconv.Itoa(max(ceilSeconds(res.RetryAfter), 1))
+	}
+	return values
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// MiddlewareWithOptions 可配置 key 与配额的限流中间件，如每个用户在每条路由上单独计数：
+//
+//	MiddlewareWithOptions(m, MiddlewareOptions{Key: ByUid()})
+func MiddlewareWithOptions(m KeyLimiter, opts MiddlewareOptions) server.ContextHandler {
+	if opts.Rule == nil {
+		opts.Rule = ByPath()
+	}
+	return func(c *server.Context) {
+		rule := opts.Rule(c)
+		key := rule
+		if opts.Key != nil {
+			key = rule + ":" + opts.Key(c)
+		}
+
+		res := m.TakeRule(rule, key)
+		setHeaders(c.GinCtx.Writer.Header(), res)
+		if !res.Allowed {
+			logger.Errorf("%s has too many requests", key)
+			c.Fail(http.StatusTooManyRequests)
+			return
+		}
+		defer res.Release()
+		c.Next()
+	}
+}
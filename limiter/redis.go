@@ -0,0 +1,165 @@
+package limiter
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/lance4117/gofuse/logger"
+	"github.com/lance4117/gofuse/store/kvs"
+	"github.com/redis/go-redis/v9"
+)
+
+// gcraScript GCRA 限流脚本，key 中保存理论到达时间（TAT），以 Redis 服务器时间为准避免各副本时钟偏差
+//
+//	ARGV: rate(每秒) burst
+//	返回: allowed remaining retry_after(秒) reset_after(秒)
+var gcraScript = redis.NewScript(`
+redis.replicate_commands()
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local interval = 1 / rate
+local now = redis.call("TIME")
+now = tonumber(now[1]) + tonumber(now[2]) / 1000000
+
+local tat = tonumber(redis.call("GET", KEYS[1]) or now)
+tat = math.max(tat, now)
+local new_tat = tat + interval
+local diff = now - (new_tat - burst * interval)
+
+if diff < 0 then
+  return {0, 0, tostring(-diff), tostring(tat - now)}
+end
+
+local reset_after = new_tat - now
+redis.call("SET", KEYS[1], tostring(new_tat), "PX", math.ceil(reset_after * 1000))
+return {1, math.floor(diff / interval), "0", tostring(reset_after)}
+`)
+
+// RedisOptions Redis 限流配置
+type RedisOptions struct {
+	Prefix   string         // key 前缀，默认 "limiter:"
+	Timeout  time.Duration  // 单次请求 Redis 的超时时间，默认 100ms
+	Cooldown time.Duration  // Redis 不可用后改用本地限流的时长，到期后再次尝试 Redis，默认 1s
+	Local    ManagerOptions // 本地兜底限流的内存控制配置
+}
+
+// RedisManager 基于 Redis 的分布式限流，多个副本共享同一配额；
+// 使用 GCRA 算法，语义与令牌桶一致：Rate 为每秒补充速率，Burst 为峰值容量，忽略 Config.Algorithm。
+// Redis 不可用时退化为本地 Manager 限流，此时各副本独立计数
+type RedisManager struct {
+	cli        *redis.Client
+	ownsClient bool // cli 由 NewRedisManager 创建，Close 时一并关闭
+	opts       RedisOptions
+	local      *Manager // 本地兜底，同时提供配置
+
+	downUntil atomic.Int64 // Redis 不可用的截止时间，UnixNano
+}
+
+// NewRedisManager 根据 kvs.RedisConfig 创建分布式限流，Redis 暂时不可用时不返回错误，先使用本地限流
+func NewRedisManager(cfg kvs.RedisConfig, config map[string]Config, opts RedisOptions) *RedisManager {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+		PoolSize: cfg.PoolSize,
+	})
+	m := NewRedisManagerWithClient(client, config, opts)
+	m.ownsClient = true
+	return m
+}
+
+// NewRedisManagerWithClient 使用已有的 Redis 客户端创建分布式限流，cli 可复用 rediskv.RedisStore.RedisCli，
+// cli 的生命周期由调用方管理
+func NewRedisManagerWithClient(cli *redis.Client, config map[string]Config, opts RedisOptions) *RedisManager {
+	if opts.Prefix == "" {
+		opts.Prefix = "limiter:"
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 100 * time.Millisecond
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = time.Second
+	}
+	return &RedisManager{
+		cli:   cli,
+		opts:  opts,
+		local: NewLimiterManagerWithOptions(config, opts.Local),
+	}
+}
+
+// Allow 尝试放行一次
+func (m *RedisManager) Allow(key string) bool {
+	return m.Take(key).Allowed
+}
+
+// Take 尝试放行一次，并返回剩余配额等信息
+func (m *RedisManager) Take(key string) Result {
+	return m.TakeRule(key, key)
+}
+
+// TakeRule 使用 rule 对应的配置对 key 限流
+func (m *RedisManager) TakeRule(rule, key string) Result {
+	if time.Now().UnixNano() < m.downUntil.Load() {
+		return m.local.TakeRule(rule, key)
+	}
+
+	cfg := m.local.effectiveConfig(rule)
+	ctx, cancel := context.WithTimeout(context.Background(), m.opts.Timeout)
+	defer cancel()
+	values, err := gcraScript.Run(ctx, m.cli, []string{m.opts.Prefix + key}, cfg.Rate, cfg.Burst).Slice()
+	if err == nil && len(values) != 4 {
+		err = redis.Nil
+	}
+	if err != nil {
+		m.markDown(err)
+		return m.local.TakeRule(rule, key)
+	}
+
+	allowed, _ := values[0].(int64)
+	remaining, _ := values[1].(int64)
+	return Result{
+		Allowed:    allowed == 1,
+		Limit:      cfg.Burst,
+		Remaining:  int(remaining),
+		RetryAfter: seconds(values[2]),
+		ResetAfter: seconds(values[3]),
+	}
+}
+
+// Wait 阻塞等待放行，支持 context
+func (m *RedisManager) Wait(ctx context.Context, key string) error {
+	return waitFor(ctx, func() Result { return m.Take(key) })
+}
+
+// UpdateConfig 替换配置表，Redis 中的配额按新配置计算，本地兜底同步更新
+func (m *RedisManager) UpdateConfig(config map[string]Config) {
+	m.local.UpdateConfig(config)
+}
+
+// Close 结束本地兜底限流的后台清理，通过 NewRedisManager 创建时同时关闭 Redis 客户端
+func (m *RedisManager) Close() error {
+	m.local.Stop()
+	if !m.ownsClient {
+		return nil
+	}
+	return m.cli.Close()
+}
+
+// markDown 记录 Redis 不可用，冷却期内改用本地限流
+func (m *RedisManager) markDown(err error) {
+	until := time.Now().Add(m.opts.Cooldown).UnixNano()
+	if old := m.downUntil.Swap(until); old < time.Now().UnixNano() {
+		logger.Warnf("limiter: redis unavailable, fall back to local limiter for %s: %v", m.opts.Cooldown, err)
+	}
+}
+
+func seconds(v any) time.Duration {
+	s, _ := v.(string)
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f <= 0 {
+		return 0
+	}
+	return time.Duration(f * float64(time.Second))
+}
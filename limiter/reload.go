@@ -0,0 +1,30 @@
+package limiter
+
+import (
+	"github.com/lance4117/gofuse/config"
+	"github.com/lance4117/gofuse/logger"
+)
+
+// Reloadable 支持动态更新配置表，Manager 与 RedisManager 均实现
+type Reloadable interface {
+	UpdateConfig(config map[string]Config)
+}
+
+var (
+	_ Reloadable = (*Manager)(nil)
+	_ Reloadable = (*RedisManager)(nil)
+)
+
+// WatchConfig 从 config 包加载 key（如 "limiter.routes"）对应的配置表，并在配置文件变更时实时应用；
+// 需先调用 config.Init。注意 viper 会将配置表中的 key 转为小写，且 "." 视为层级分隔符
+func WatchConfig(m Reloadable, key string) error {
+	routes, err := config.LoadKey[map[string]Config](key)
+	if err != nil {
+		return err
+	}
+	m.UpdateConfig(routes)
+	return config.WatchKey(key, func(routes map[string]Config) {
+		logger.Infof("limiter: reload %s, %d routes", key, len(routes))
+		m.UpdateConfig(routes)
+	})
+}
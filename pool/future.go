@@ -0,0 +1,112 @@
+package pool
+
+import (
+	"context"
+	"sync"
+)
+
+// Future 异步任务的结果
+type Future[T any] struct {
+	done chan struct{}
+	val  T
+	err  error
+}
+
+// SubmitFuture 提交一个带类型结果的任务，结果只写入返回的 Future，无需消费 Results；
+// 提交失败时 Future 立即完成并返回该错误，任务 panic 时返回 *PanicError
+func SubmitFuture[T any](p *Pool, fn func(ctx context.Context) (T, error)) *Future[T] {
+	return SubmitFutureCtx(context.Background(), p, fn)
+}
+
+// SubmitFutureCtx 同 SubmitFuture，fn 收到的 context 派生自 ctx，开始执行前 ctx 已结束则不执行
+func SubmitFutureCtx[T any](ctx context.Context, p *Pool, fn func(ctx context.Context) (T, error), opts ...TaskOption) *Future[T] {
+	f := &Future[T]{done: make(chan struct{})}
+	o := applyTaskOptions(opts)
+	err := ctx.Err()
+	if err == nil {
+		err = p.Go(func() {
+			defer close(f.done)
+			f.val, f.err = runCtx(p, ctx, o, fn)
+		})
+	}
+	if err != nil {
+		f.err = err
+		close(f.done)
+	}
+	return f
+}
+
+// Get 等待任务完成并返回结果，ctx 结束时返回 ctx.Err()，任务仍会继续执行
+func (f *Future[T]) Get(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// Done 任务完成时关闭
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Map 使用 p 并发处理 items，结果与 items 顺序一致；
+// 出现错误或 panic 时取消 ctx，尚未开始的任务不再执行，返回最先出现的错误。
+// 不要在 p 的任务中调用，池满时提交会阻塞，可能导致死锁
+func Map[T, R any](ctx context.Context, p *Pool, items []T, fn func(ctx context.Context, item T) (R, error)) ([]R, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	out := make([]R, len(items))
+	var (
+		wg    sync.WaitGroup
+		once  sync.Once
+		first error
+	)
+	fail := func(err error) {
+		once.Do(func() {
+			first = err
+			cancel()
+		})
+	}
+
+	for i, item := range items {
+		if err := ctx.Err(); err != nil {
+			fail(err)
+			break
+		}
+		wg.Add(1)
+		err := p.Go(func() {
+			defer wg.Done()
+			if err := ctx.Err(); err != nil {
+				fail(err)
+				return
+			}
+			val, err := protect(func() (R, error) {
+				return fn(ctx, item)
+			})
+			if err != nil {
+				fail(err)
+				return
+			}
+			out[i] = val
+		})
+		if err != nil {
+			wg.Done()
+			fail(err)
+			break
+		}
+	}
+	wg.Wait()
+	return out, first
+}
+
+// ForEach 使用 p 并发处理 items，错误处理同 Map
+func ForEach[T any](ctx context.Context, p *Pool, items []T, fn func(ctx context.Context, item T) error) error {
+	_, err := Map(ctx, p, items, func(ctx context.Context, item T) (struct{}, error) {
+		return struct{}{}, fn(ctx, item)
+	})
+	return err
+}
@@ -0,0 +1,117 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// GroupOptions 任务组配置
+type GroupOptions struct {
+	Limit      int  // 组内同时执行的任务上限，0 表示不限（仍受 Pool 大小约束）；多个组共享 Pool 时可避免单个组占满 worker
+	CollectAll bool // 为 true 时出错不取消其他任务，Wait 返回 errors.Join 合并的全部错误；默认出错即取消，只返回第一个错误
+}
+
+// Group 在 Pool 中执行一组任务并等待全部完成，类似 errgroup
+type Group struct {
+	pool   *Pool
+	opts   GroupOptions
+	ctx    context.Context
+	cancel context.CancelFunc
+	sem    chan struct{}
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	errs    []error
+	skipped bool // 已记录因 ctx 结束而跳过的任务
+}
+
+// NewGroup 创建任务组，返回的 context 在出错（非 CollectAll）或 Wait 返回时取消
+func NewGroup(ctx context.Context, p *Pool, opts GroupOptions) (*Group, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	g := &Group{pool: p, opts: opts, ctx: ctx, cancel: cancel}
+	if opts.Limit > 0 {
+		g.sem = make(chan struct{}, opts.Limit)
+	}
+	return g, ctx
+}
+
+// Go 提交一个任务，达到 Limit 时阻塞到有任务完成；组的 context 已结束时不再执行。
+// 不要在同一 Pool 的任务中调用，池满时可能导致死锁
+func (g *Group) Go(fn func(ctx context.Context) error) {
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		case <-g.ctx.Done():
+			g.skip(g.ctx.Err())
+			return
+		}
+	}
+
+	g.wg.Add(1)
+	err := g.pool.Go(func() {
+		defer g.done()
+		if err := g.ctx.Err(); err != nil {
+			g.skip(err)
+			return
+		}
+		_, err := protect(func() (struct{}, error) {
+			return struct{}{}, fn(g.ctx)
+		})
+		if err != nil {
+			g.fail(err)
+		}
+	})
+	if err != nil {
+		g.done()
+		g.fail(err)
+	}
+}
+
+// Wait 等待所有任务完成，返回第一个错误，CollectAll 时返回合并的全部错误
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.errs) == 0 {
+		return nil
+	}
+	if g.opts.CollectAll {
+		return errors.Join(g.errs...)
+	}
+	return g.errs[0]
+}
+
+func (g *Group) done() {
+	if g.sem != nil {
+		<-g.sem
+	}
+	g.wg.Done()
+}
+
+// fail 记录任务错误，非 CollectAll 时取消其他任务
+func (g *Group) fail(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.opts.CollectAll && len(g.errs) > 0 {
+		return
+	}
+	g.errs = append(g.errs, err)
+	if !g.opts.CollectAll {
+		g.cancel()
+	}
+}
+
+// skip 记录因 ctx 结束而跳过的任务，同一原因只记录一次
+func (g *Group) skip(err error) {
+	g.mu.Lock()
+	if g.skipped {
+		g.mu.Unlock()
+		return
+	}
+	g.skipped = true
+	g.mu.Unlock()
+	g.fail(err)
+}
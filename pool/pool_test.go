@@ -1,9 +1,16 @@
 package pool
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/lance4117/gofuse/errs"
 )
 
 func task(val int) TaskFunc {
@@ -37,3 +44,185 @@ func TestWorkerPool(t *testing.T) {
 	wp.Wait()
 	wp.Release()
 }
+
+func TestSubmitFuture(t *testing.T) {
+	wp, _ := New(2)
+	defer wp.Release()
+
+	futures := make([]*Future[int], 5)
+	for i := range futures {
+		futures[i] = SubmitFuture(wp, func(ctx context.Context) (int, error) {
+			time.Sleep(10 * time.Millisecond)
+			return i * i, nil
+		})
+	}
+	for i, f := range futures {
+		v, err := f.Get(context.Background())
+		if err != nil || v != i*i {
+			t.Fatalf("future %d: got %d, %v", i, v, err)
+		}
+	}
+
+	slow := SubmitFuture(wp, func(ctx context.Context) (string, error) {
+		time.Sleep(200 * time.Millisecond)
+		return "late", nil
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := slow.Get(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestMap(t *testing.T) {
+	wp, _ := New(3)
+	defer wp.Release()
+
+	items := []int{5, 1, 4, 2, 3}
+	out, err := Map(context.Background(), wp, items, func(ctx context.Context, n int) (string, error) {
+		time.Sleep(time.Duration(n) * 5 * time.Millisecond)
+		return fmt.Sprint(n * 10), nil
+	})
+	if err != nil || !slices.Equal(out, []string{"50", "10", "40", "20", "30"}) {
+		t.Fatalf("unexpected result %v, %v", out, err)
+	}
+
+	boom := errors.New("boom")
+	err = ForEach(context.Background(), wp, []int{1, 2, 3}, func(ctx context.Context, n int) error {
+		if n == 2 {
+			return boom
+		}
+		return nil
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected boom, got %v", err)
+	}
+}
+
+func TestPanicAndTimeout(t *testing.T) {
+	wp, _ := New(2)
+	defer wp.Release()
+
+	_ = wp.Submit(func() (any, error) {
+		panic("oops")
+	})
+	_ = wp.SubmitCtx(context.Background(), func(ctx context.Context) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, WithTimeout(20*time.Millisecond))
+	wp.Wait()
+
+	var panicked, timedOut bool
+	for range 2 {
+		r := <-wp.Results()
+		var pe *PanicError
+		switch {
+		case errors.As(r.Err, &pe):
+			panicked = errors.Is(r.Err, errs.ErrTaskPanic) && pe.Value == "oops" &&
+				strings.Contains(string(pe.Stack), "pool_test.go")
+		case errors.Is(r.Err, context.DeadlineExceeded):
+			timedOut = true
+		}
+	}
+	if !panicked || !timedOut {
+		t.Fatalf("panicked=%v timedOut=%v", panicked, timedOut)
+	}
+
+	f := SubmitFuture(wp, func(ctx context.Context) (int, error) {
+		panic("future")
+	})
+	if _, err := f.Get(context.Background()); !errors.Is(err, errs.ErrTaskPanic) {
+		t.Fatalf("expected panic error, got %v", err)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	wp, _ := New(2)
+	_ = wp.SubmitCtx(context.Background(), func(ctx context.Context) (any, error) {
+		time.Sleep(20 * time.Millisecond)
+		return "done", nil
+	})
+	go func() {
+		for range wp.Results() {
+		}
+	}()
+	if err := wp.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if err := wp.Go(func() {}); !errors.Is(err, errs.ErrPoolClosed) {
+		t.Fatalf("expected pool closed, got %v", err)
+	}
+
+	wp2, _ := New(1)
+	canceled := make(chan struct{})
+	_ = wp2.SubmitCtx(context.Background(), func(ctx context.Context) (any, error) {
+		<-ctx.Done()
+		close(canceled)
+		return nil, ctx.Err()
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := wp2.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("running task not canceled after shutdown timeout")
+	}
+}
+
+func TestGroup(t *testing.T) {
+	wp, _ := New(4)
+	defer wp.Release()
+
+	// 并发上限
+	g, _ := NewGroup(context.Background(), wp, GroupOptions{Limit: 2})
+	var running, peak atomic.Int32
+	for range 6 {
+		g.Go(func(ctx context.Context) error {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			running.Add(-1)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil || peak.Load() > 2 {
+		t.Fatalf("err=%v peak=%d", err, peak.Load())
+	}
+
+	// 出错即取消
+	boom := errors.New("boom")
+	g, ctx := NewGroup(context.Background(), wp, GroupOptions{})
+	g.Go(func(ctx context.Context) error {
+		return boom
+	})
+	g.Go(func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return errors.New("not canceled")
+		}
+	})
+	if err := g.Wait(); !errors.Is(err, boom) || ctx.Err() == nil {
+		t.Fatalf("expected boom and canceled ctx, got %v", err)
+	}
+
+	// 收集全部错误
+	other := errors.New("other")
+	g, _ = NewGroup(context.Background(), wp, GroupOptions{CollectAll: true})
+	g.Go(func(ctx context.Context) error { return boom })
+	g.Go(func(ctx context.Context) error { return other })
+	g.Go(func(ctx context.Context) error { panic("group") })
+	err := g.Wait()
+	if !errors.Is(err, boom) || !errors.Is(err, other) || !errors.Is(err, errs.ErrTaskPanic) {
+		t.Fatalf("expected all errors, got %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/lance4117/gofuse/errs"
+)
+
+// PanicError 任务 panic 时返回的错误，errors.Is(err, errs.ErrTaskPanic) 为 true
+type PanicError struct {
+	Value any    // recover 得到的值
+	Stack []byte // panic 时的调用栈
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%s: %v\n%s", errs.ErrTaskPanic.Error(), e.Value, e.Stack)
+}
+
+func (e *PanicError) Unwrap() error {
+	return errs.ErrTaskPanic
+}
+
+// TaskOption 单个任务的选项
+type TaskOption func(*taskOptions)
+
+type taskOptions struct {
+	timeout time.Duration
+}
+
+// WithTimeout 任务的执行时限，从开始执行时计算，超时后取消任务的 context
+func WithTimeout(d time.Duration) TaskOption {
+	return func(o *taskOptions) {
+		o.timeout = d
+	}
+}
+
+func applyTaskOptions(opts []TaskOption) taskOptions {
+	var o taskOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// protect 执行 fn，panic 转为 *PanicError
+func protect[T any](fn func() (T, error)) (val T, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return fn()
+}
+
+// runCtx 以 ctx 执行 fn：ctx 已结束时不执行，按 opts 设置时限，Shutdown 超时后取消
+func runCtx[T any](wp *Pool, ctx context.Context, opts taskOptions, fn func(ctx context.Context) (T, error)) (T, error) {
+	if err := ctx.Err(); err != nil {
+		var zero T
+		return zero, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(wp.ctx, cancel)
+	defer stop()
+	if opts.timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, opts.timeout)
+		defer cancelTimeout()
+	}
+	return protect(func() (T, error) {
+		return fn(ctx)
+	})
+}
@@ -1,14 +1,25 @@
+// Package pool 基于 Ants 的 goroutine 池。
+//
+// Pool 的任务可感知 context 与超时，panic 转为 *PanicError，支持 Shutdown 优雅关闭；
+// SubmitFuture 返回带类型结果的 Future，Map/ForEach 并发处理并保持输入顺序；
+// Group 可限制并发，出错即取消其余任务或收集全部错误。
 package pool
 
 import (
+	"context"
 	"sync"
 
+	"github.com/lance4117/gofuse/errs"
+	"github.com/lance4117/gofuse/logger"
 	"github.com/panjf2000/ants/v2"
 )
 
 // TaskFunc 定义任务函数
 type TaskFunc func() (any, error)
 
+// ContextTaskFunc 定义可感知取消的任务函数
+type ContextTaskFunc func(ctx context.Context) (any, error)
+
 // Result 表示任务执行结果
 type Result struct {
 	Value any
@@ -20,23 +31,54 @@ type Pool struct {
 	pool   *ants.PoolWithFunc
 	wg     sync.WaitGroup
 	result chan Result
+
+	intake sync.RWMutex // 保护 closed 与 wg.Add，避免 Shutdown 等待期间继续提交
+	closed bool
+
+	ctx    context.Context // Shutdown 超时后取消，通知仍在运行的任务
+	cancel context.CancelFunc
+
+	quit       chan struct{} // 关闭后不再写入结果通道
+	resultMu   sync.RWMutex
+	resultDone bool
+	releaseOne sync.Once
+}
+
+// ctxTask 可感知取消的任务
+type ctxTask struct {
+	ctx  context.Context
+	fn   ContextTaskFunc
+	opts taskOptions
 }
 
 // New 创建一个新的 worker pool
 // size: 池子大小
 func New(size int) (*Pool, error) {
-	wp := &Pool{}
+	wp := &Pool{quit: make(chan struct{})}
 	var err error
 
 	wp.result = make(chan Result, size*2) // 结果缓冲
+	wp.ctx, wp.cancel = context.WithCancel(context.Background())
 
 	wp.pool, err = ants.NewPoolWithFunc(size, func(task interface{}) {
-		if fn, ok := task.(TaskFunc); ok {
-			val, err := fn()
-			if wp.result != nil {
-				wp.result <- Result{Value: val, Err: err}
+		defer wp.wg.Done()
+		switch fn := task.(type) {
+		case TaskFunc:
+			val, err := protect(fn)
+			wp.emit(Result{Value: val, Err: err})
+		case *ctxTask:
+			val, err := runCtx(wp, fn.ctx, fn.opts, func(ctx context.Context) (any, error) {
+				return fn.fn(ctx)
+			})
+			wp.emit(Result{Value: val, Err: err})
+		case func():
+			_, err := protect(func() (struct{}, error) {
+				fn()
+				return struct{}{}, nil
+			})
+			if err != nil {
+				logger.Errorf("%v", err)
 			}
-			wp.wg.Done()
 		}
 	})
 	if err != nil {
@@ -46,10 +88,54 @@ func New(size int) (*Pool, error) {
 	return wp, nil
 }
 
-// Submit 提交一个任务
-func (wp *Pool) Submit(task TaskFunc) error {
+// invoke 提交任务到 ants，池已关闭时返回 errs.ErrPoolClosed
+func (wp *Pool) invoke(task any) error {
+	wp.intake.RLock()
+	if wp.closed {
+		wp.intake.RUnlock()
+		return errs.ErrPoolClosed
+	}
 	wp.wg.Add(1)
-	return wp.pool.Invoke(task)
+	wp.intake.RUnlock()
+
+	if err := wp.pool.Invoke(task); err != nil {
+		wp.wg.Done()
+		return err
+	}
+	return nil
+}
+
+// emit 写入结果通道，Shutdown 超时或 Release 后丢弃
+func (wp *Pool) emit(res Result) {
+	wp.resultMu.RLock()
+	defer wp.resultMu.RUnlock()
+	if wp.resultDone {
+		return
+	}
+	select {
+	case wp.result <- res:
+	case <-wp.quit:
+	}
+}
+
+// Submit 提交一个任务，结果写入 Results 通道，需持续消费，否则缓冲写满后 worker 会阻塞；
+// 需要按任务获取结果时使用 SubmitFuture。任务 panic 时 Result.Err 为 *PanicError
+func (wp *Pool) Submit(task TaskFunc) error {
+	return wp.invoke(task)
+}
+
+// SubmitCtx 提交一个可感知取消的任务，结果写入 Results 通道；
+// 开始执行前 ctx 已结束则不执行，Result.Err 为 ctx.Err()。池满时提交仍会阻塞
+func (wp *Pool) SubmitCtx(ctx context.Context, task ContextTaskFunc, opts ...TaskOption) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return wp.invoke(&ctxTask{ctx: ctx, fn: task, opts: applyTaskOptions(opts)})
+}
+
+// Go 提交一个无返回值的任务，结果不写入结果通道，无需消费 Results；任务 panic 时记录错误日志
+func (wp *Pool) Go(fn func()) error {
+	return wp.invoke(fn)
 }
 
 // Results 获取结果通道
@@ -62,8 +148,43 @@ func (wp *Pool) Wait() {
 	wp.wg.Wait()
 }
 
-// Release 释放资源
+// Shutdown 停止接收新任务并等待已提交的任务完成；
+// ctx 结束时取消所有任务的 context，立即释放资源并返回 ctx.Err()，之后完成的任务结果被丢弃
+func (wp *Pool) Shutdown(ctx context.Context) error {
+	wp.intake.Lock()
+	wp.closed = true
+	wp.intake.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		wp.wg.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+		wp.cancel()
+	}
+	wp.Release()
+	return err
+}
+
+// Release 释放资源，可重复调用
 func (wp *Pool) Release() {
-	wp.pool.Release()
-	close(wp.result)
+	wp.releaseOne.Do(func() {
+		wp.intake.Lock()
+		wp.closed = true
+		wp.intake.Unlock()
+
+		wp.pool.Release()
+		close(wp.quit)
+		wp.resultMu.Lock()
+		wp.resultDone = true
+		close(wp.result)
+		wp.resultMu.Unlock()
+		wp.cancel()
+	})
 }